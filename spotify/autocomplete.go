@@ -0,0 +1,157 @@
+package spotify
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/eris/utils"
+)
+
+// autocompleteFileLimit and autocompleteTrackLimit cap how many local files and spotify tracks are suggested. Each
+// spotify suggestion needs its own metadata lookup, and discord only waits a few seconds for suggestions.
+const autocompleteFileLimit = 10
+const autocompleteTrackLimit = 5
+
+// maxChoiceLength is the longest name or value discord allows for an autocomplete choice.
+const maxChoiceLength = 100
+
+const trackLinkStr = "https://open.spotify.com/track/%s"
+
+func (p *Plugin) autocompleteHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	command := i.ApplicationCommandData()
+	if command.Name != "spotify" || len(command.Options) == 0 {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	switch command.Options[0].Name {
+	case "play":
+		queryOption := utils.GetCommandOption(*command.Options[0], "play", "query")
+		if queryOption == nil || !queryOption.Focused {
+			return
+		}
+
+		choices = p.queryChoices(i.Interaction.GuildID, strings.TrimSpace(queryOption.StringValue()), logger)
+	case "playlist", "files":
+		if len(command.Options[0].Options) == 0 {
+			return
+		}
+
+		subOption := command.Options[0].Options[0]
+		nameOption := utils.GetCommandOption(*subOption, subOption.Name, "name")
+		if nameOption == nil || !nameOption.Focused {
+			return
+		}
+
+		if command.Options[0].Name == "files" {
+			choices = p.fileChoices(i.Interaction.GuildID, strings.TrimSpace(nameOption.StringValue()))
+		} else {
+			choices = p.playlistChoices(i.Interaction.GuildID, strings.TrimSpace(nameOption.StringValue()))
+		}
+	default:
+		return
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Response(&discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{Choices: choices},
+		}).
+		SendWithLog(logger)
+}
+
+// queryChoices returns the local files and spotify tracks that match query as autocomplete choices.
+func (p *Plugin) queryChoices(guildId string, query string, logger *slog.Logger) []*discordgo.ApplicationCommandOptionChoice {
+	choices := p.fileChoices(guildId, query)
+
+	// Searching spotify for nothing just returns nothing
+	if spotSession, ok := p.sessions.Get(guildId); ok && spotSession.session.LoggedIn() && query != "" {
+		tracks, err := spotSession.session.Search(query).Limit(autocompleteTrackLimit).Tracks()
+		if err != nil {
+			logger.Error("spotify search failed", slog.String("error", err.Error()))
+		}
+
+		for _, t := range tracks {
+			// Suggestions are sent as links so that picking one queues that exact track
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  truncate(fmt.Sprintf("%s - %s", t.Name(), t.Artist()), maxChoiceLength),
+				Value: fmt.Sprintf(trackLinkStr, t.Id()),
+			})
+		}
+	}
+
+	return choices
+}
+
+// playlistChoices returns the guild's saved playlists whose names contain query as autocomplete choices.
+func (p *Plugin) playlistChoices(guildId string, query string) []*discordgo.ApplicationCommandOptionChoice {
+	playlists, err := loadPlaylists(guildId)
+	if err != nil {
+		return nil
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, playlist := range sortedPlaylists(playlists) {
+		// Discord doesn't allow more choices than this
+		if len(choices) == 25 {
+			break
+		}
+
+		if strings.Contains(playlistKey(playlist.Name), playlistKey(query)) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  truncate(fmt.Sprintf("%s (%d songs)", playlist.Name, len(playlist.Tracks)), maxChoiceLength),
+				Value: playlist.Name,
+			})
+		}
+	}
+
+	return choices
+}
+
+// fileChoices returns the local files the given guild can play whose path or tags match query as autocomplete choices.
+func (p *Plugin) fileChoices(guildId string, query string) []*discordgo.ApplicationCommandOptionChoice {
+	// Outside of a guild there's no library to suggest from
+	if !isGuildId(guildId) {
+		return nil
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, entry := range p.searchLocalFiles(guildId, query) {
+		if len(choices) == autocompleteFileLimit {
+			break
+		}
+
+		// A truncated value wouldn't match the file anymore
+		if len(entry.ref()) > maxChoiceLength {
+			continue
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncate(fmt.Sprintf("%s (%s)", entry.label(), entry.origin()), maxChoiceLength),
+			Value: entry.ref(),
+		})
+	}
+
+	return choices
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,146 @@
+package spotify
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/olympus-go/apollo"
+	"golang.org/x/exp/slices"
+)
+
+// autoplayRequester is used as the requester of anything autoplay queues, so it's clear in the queue where it came from.
+const autoplayRequester = "autoplay"
+
+// autoplaySearchLimit is how many search results are considered for each seed artist.
+const autoplaySearchLimit = 20
+
+// fillAutoplay queues something to play next if autoplay is enabled and the queue has run dry. Picking a track can take
+// several spotify lookups, so it happens in the background rather than holding up the player or whoever called this.
+// Only one fill runs at a time.
+func (s *session) fillAutoplay() {
+	if !s.autoplay || len(s.player.List(false)) > 0 {
+		return
+	}
+
+	if !s.autoplayFilling.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.autoplayFilling.Store(false)
+
+		t := s.autoplayNext()
+		if t == nil {
+			return
+		}
+
+		// Someone may have queued something themselves in the meantime
+		s.queueMu.Lock()
+		if len(s.player.List(false)) > 0 {
+			s.queueMu.Unlock()
+			return
+		}
+		s.player.Enqueue(t)
+		s.queueMu.Unlock()
+
+		// If the player already ran out it's sitting idle, and needs a nudge to pick up what was just queued
+		if s.player.State() == apollo.IdleState {
+			s.player.Play()
+		}
+		s.panelChanged()
+	}()
+}
+
+// autoplayNext picks a track related to the last few that were played. Spotify is searched for more songs by the same
+// artists, and if that isn't possible (e.g. the session isn't logged in, or only local files were played) a random track
+// from the local library is picked instead. Anything played recently or banned is passed over. nil is returned if
+// nothing suitable could be found.
+func (s *session) autoplayNext() apollo.Playable {
+	history := s.player.List(true)[:s.player.Cursor()]
+	seeds := history[max(len(history)-s.autoplaySeeds, 0):]
+
+	played := make(map[string]bool)
+	for _, t := range history {
+		played[duplicateKey(t)] = true
+	}
+
+	if s.session.LoggedIn() {
+		if t := s.autoplaySpotify(seeds, played); t != nil {
+			return t
+		}
+	}
+
+	return s.autoplayLocal(played)
+}
+
+// autoplaySpotify searches spotify for another song by one of the artists in seeds.
+func (s *session) autoplaySpotify(seeds []apollo.Playable, played map[string]bool) apollo.Playable {
+	var artists []string
+	for _, seed := range seeds {
+		if t, ok := seed.(*track); ok && !slices.Contains(artists, t.Artist()) {
+			artists = append(artists, t.Artist())
+		}
+	}
+	rand.Shuffle(len(artists), func(i, j int) { artists[i], artists[j] = artists[j], artists[i] })
+
+	for _, artist := range artists {
+		trackIds, err := s.session.Search(artist).Limit(autoplaySearchLimit).TrackIds()
+		if err != nil {
+			continue
+		}
+		rand.Shuffle(len(trackIds), func(i, j int) { trackIds[i], trackIds[j] = trackIds[j], trackIds[i] })
+
+		for _, trackId := range trackIds {
+			if played["spotify:"+trackId] {
+				continue
+			}
+
+			spotTrack, err := s.session.GetTrackById(trackId)
+			if err != nil {
+				continue
+			}
+
+			t := &track{
+				Track: spotTrack,
+				metadata: map[string]string{
+					"requesterId":   autoplayRequester,
+					"requesterName": autoplayRequester,
+					"frequency":     fmt.Sprintf("%d", discordFrequency),
+				},
+			}
+
+			if s.banned == nil || !s.banned(t) {
+				return t
+			}
+		}
+	}
+
+	return nil
+}
+
+// autoplayLocal picks a random file from the local library.
+func (s *session) autoplayLocal(played map[string]bool) apollo.Playable {
+	var entries []libraryEntry
+	for _, l := range s.libraries {
+		entries = append(entries, l.list("")...)
+	}
+	rand.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+
+	for _, entry := range entries {
+		if played["file:"+entry.fullPath()] {
+			continue
+		}
+
+		file, err := entry.open(autoplayRequester, autoplayRequester)
+		if err != nil {
+			continue
+		}
+
+		t := &file
+		if s.banned == nil || !s.banned(t) {
+			return t
+		}
+	}
+
+	return nil
+}
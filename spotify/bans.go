@@ -0,0 +1,208 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/olympus-go/apollo"
+	"github.com/olympus-go/apollo/spotify"
+	"golang.org/x/exp/slices"
+)
+
+const bansFilename = "bans.json"
+
+// guildBans are the bans a guild's admins added with the ban command. They apply on top of the ones in the config,
+// which cover every guild.
+type guildBans struct {
+	Tracks  []string `json:"tracks"`
+	Artists []string `json:"artists"`
+}
+
+// banStore keeps every guild's bans in memory, loading them from disk the first time they're needed and writing them
+// back whenever they change.
+type banStore struct {
+	mu     sync.Mutex
+	guilds map[string]guildBans
+}
+
+func newBanStore() *banStore {
+	return &banStore{guilds: make(map[string]guildBans)}
+}
+
+// get returns the guild's bans. The slices are never modified in place, so they're safe to read after b.mu is
+// released.
+func (b *banStore) get(guildId string) (guildBans, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.load(guildId)
+}
+
+// update applies update to the guild's bans and writes them back to disk. The in-memory bans are only replaced once
+// the write succeeds.
+func (b *banStore) update(guildId string, update func(*guildBans)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bans, err := b.load(guildId)
+	if err != nil {
+		return err
+	}
+
+	bans.Tracks = slices.Clone(bans.Tracks)
+	bans.Artists = slices.Clone(bans.Artists)
+	update(&bans)
+
+	data, err := json.Marshal(bans)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(guildDir(guildId), bansFilename)
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// Same as snapshots, write to a temporary file first so a crash mid-write doesn't lose every ban.
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	b.guilds[guildId] = bans
+
+	return nil
+}
+
+// load returns the guild's bans, reading them from disk if they aren't in memory yet. The caller is expected to hold
+// b.mu.
+func (b *banStore) load(guildId string) (guildBans, error) {
+	if bans, ok := b.guilds[guildId]; ok {
+		return bans, nil
+	}
+
+	var bans guildBans
+	data, err := os.ReadFile(filepath.Join(guildDir(guildId), bansFilename))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return guildBans{}, err
+	} else if err == nil {
+		if err = json.Unmarshal(data, &bans); err != nil {
+			return guildBans{}, err
+		}
+	}
+
+	b.guilds[guildId] = bans
+
+	return bans, nil
+}
+
+// compileBanPatterns compiles every pattern to match case-insensitively. Invalid patterns are logged and left out.
+func compileBanPatterns(patterns []string, logger *slog.Logger) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			logger.Error("invalid ban pattern", slog.String("error", err.Error()), slog.String("pattern", pattern))
+			continue
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled
+}
+
+// guildBans returns the guild's bans, or none if guildId is empty or they can't be read.
+func (p *Plugin) guildBans(guildId string) guildBans {
+	if guildId == "" {
+		return guildBans{}
+	}
+
+	bans, err := p.bans.get(guildId)
+	if err != nil {
+		p.logger.Error("failed to load bans", slog.String("error", err.Error()), slog.String("guild_id", guildId))
+	}
+
+	return bans
+}
+
+// isBannedTrack reports whether the spotify track with the given id is banned in the guild. It's cheap enough to
+// check before the track is looked up.
+func (p *Plugin) isBannedTrack(guildId string, trackId string) bool {
+	return slices.Contains(p.config.BannedTracks, trackId) || slices.Contains(p.guildBans(guildId).Tracks, trackId)
+}
+
+// isBanned reports whether playable matches any of the bans in the guild. Track, artist and album ids only apply to
+// spotify tracks, while patterns are matched case-insensitively against the name and artist of anything.
+func (p *Plugin) isBanned(guildId string, playable apollo.Playable) bool {
+	var spotTrack *spotify.Track
+	switch t := playable.(type) {
+	case *track:
+		spotTrack = &t.Track
+	case spotify.Track:
+		spotTrack = &t
+	}
+
+	names := []string{playable.Name(), playable.Artist()}
+
+	if spotTrack != nil {
+		bans := p.guildBans(guildId)
+		if slices.Contains(p.config.BannedTracks, spotTrack.Id()) || slices.Contains(bans.Tracks, spotTrack.Id()) {
+			return true
+		}
+
+		// The lookup is a web API request the first time a track is seen, so it's skipped when there are no id bans that
+		// need it. Patterns are still matched against the track's own artist either way.
+		if len(p.config.BannedArtists) > 0 || len(bans.Artists) > 0 || len(p.config.BannedAlbums) > 0 {
+			if details, ok := p.trackDetails(*spotTrack); ok {
+				for _, artist := range details.Artists {
+					if slices.Contains(p.config.BannedArtists, artist.Id) || slices.Contains(bans.Artists, artist.Id) {
+						return true
+					}
+
+					if len(p.banPatterns) > 0 {
+						names = append(names, artist.Name)
+					}
+				}
+
+				if details.Album.Id != "" && slices.Contains(p.config.BannedAlbums, details.Album.Id) {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, re := range p.banPatterns {
+		for _, name := range names {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// trackDetails looks up the artist and album ids of t, which spotify.Track doesn't expose. ok is false if they aren't
+// available (e.g. no client credentials are configured), in which case only the bans that don't need them are
+// enforced.
+func (p *Plugin) trackDetails(t spotify.Track) (webTrack, bool) {
+	details, err := p.web.track(t.Id())
+	if err != nil {
+		if !errors.Is(err, errNoWebCredentials) {
+			p.logger.Error("failed to look up track details", slog.String("error", err.Error()), slog.String("trackId", t.Id()))
+		}
+		return webTrack{}, false
+	}
+
+	return details, true
+}
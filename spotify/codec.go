@@ -0,0 +1,512 @@
+package spotify
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olympus-go/apollo/ffmpeg"
+	"github.com/olympus-go/apollo/ogg"
+)
+
+// packetDuration is how much audio a single opus packet holds. This needs to match the FrameDuration of the encoder.
+const packetDuration = 20 * time.Millisecond
+
+// resampleQuality is the quality passed to the Resampler used for remixes.
+const resampleQuality = QualityHigh
+
+// pcmChannels is the number of interleaved channels in the raw PCM passed between the decoder and encoder.
+const pcmChannels = 2
+
+// pumpLead is how far ahead of playback PCM is allowed to be processed. Filter changes (e.g. volume) take roughly
+// this long to be heard. It needs to stay comfortably above the ogg muxer's page duration (1s) or the pipeline stalls.
+const pumpLead = 3 * time.Second
+
+var ErrNotPlaying = errors.New("nothing playing")
+
+// trackCodec implements apollo.Codec with a pair of ffmpeg processes. The first decodes the playable to raw PCM, which
+// is run through the codec's audioFilter before the second encodes it to opus.
+//
+// The playable being read is buffered in memory so the pipeline can be restarted at any offset, which is what allows
+// seeking within a playable.
+//
+// onFinish is called whenever a playable is read all the way through. Skipped playables never reach io.EOF, so
+// onFinish is only called for playables that finished on their own.
+type trackCodec struct {
+	opts     ffmpeg.Options
+	filter   *audioFilter
+	onFinish func()
+	// onOpen is called whenever a new playable starts.
+	onOpen func()
+	// onClose is called whenever a playable stops, right before the player moves on to the next one.
+	onClose func()
+
+	mu       sync.Mutex
+	source   *sourceBuffer
+	pipeline *pipeline
+	// offset is the position in the playable the current pipeline started at.
+	offset time.Duration
+	// startOffset is used as the offset for the next playable that gets opened.
+	startOffset time.Duration
+	// frequency is the rate the current playable is resampled to before being played back at discordFrequency.
+	frequency int
+
+	// packets is the number of packets read since the current pipeline started.
+	packets atomic.Int64
+}
+
+// pipeline holds everything that makes up a single run of the decoder -> filter -> encoder chain.
+type pipeline struct {
+	reader  *sourceReader
+	decoder *ffmpeg.Process
+	encoder *ffmpeg.Process
+	pw      *io.PipeWriter
+	cancel  context.CancelFunc
+}
+
+// newTrackCodec creates a trackCodec that encodes using opts. opts.Input must be ffmpeg.Stdin.
+func newTrackCodec(opts ffmpeg.Options) *trackCodec {
+	opts.Decoder = pcmFormat{}
+
+	return &trackCodec{
+		opts:   opts,
+		filter: newAudioFilter(),
+	}
+}
+
+func (c *trackCodec) Open(r io.Reader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.source = newSourceBuffer(r)
+	c.filter.reset()
+
+	c.frequency = discordFrequency
+	if m, ok := r.(interface{ Metadata() map[string]string }); ok {
+		if frequency, err := strconv.Atoi(m.Metadata()["frequency"]); err == nil && frequency > 0 {
+			c.frequency = frequency
+		}
+	}
+
+	offset := c.startOffset
+	c.startOffset = 0
+
+	if c.onOpen != nil {
+		c.onOpen()
+	}
+
+	return c.restart(offset)
+}
+
+func (c *trackCodec) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		current := c.pipeline
+		c.mu.Unlock()
+
+		if current == nil {
+			return 0, io.EOF
+		}
+
+		n, err := current.encoder.Read(b)
+
+		// If the pipeline was swapped out mid read (seek), whatever was read belongs to the old one.
+		c.mu.Lock()
+		swapped := c.pipeline != current
+		c.mu.Unlock()
+		if swapped {
+			continue
+		}
+
+		if err == nil {
+			c.packets.Add(1)
+		} else if err == io.EOF && c.onFinish != nil {
+			c.onFinish()
+		}
+
+		return n, err
+	}
+}
+
+func (c *trackCodec) Close() error {
+	if c.onClose != nil {
+		c.onClose()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.closePipeline()
+	if c.source != nil {
+		c.source.close()
+		c.source = nil
+	}
+
+	return err
+}
+
+// Seek restarts playback of the currently open playable at position d.
+func (c *trackCodec) Seek(d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.source == nil {
+		return ErrNotPlaying
+	}
+
+	if d < 0 {
+		d = 0
+	}
+
+	_ = c.closePipeline()
+
+	return c.restart(d)
+}
+
+// Position returns how far into the currently open playable playback is. This is in terms of the playable itself, so
+// remixed playables move faster or slower than real time.
+func (c *trackCodec) Position() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	played := time.Duration(c.packets.Load()) * packetDuration
+	if c.frequency > 0 {
+		played = played * discordFrequency / time.Duration(c.frequency)
+	}
+
+	return c.offset + played
+}
+
+// SetStartOffset sets the position the next opened playable should start playing from.
+func (c *trackCodec) SetStartOffset(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.startOffset = d
+}
+
+// restart starts a new pipeline reading from the beginning of the source, with output starting at offset. The caller
+// is expected to hold c.mu.
+func (c *trackCodec) restart(offset time.Duration) error {
+	decodeOpts := ffmpeg.Options{
+		Encoder: pcmFormat{},
+		Input:   ffmpeg.Stdin,
+		Output:  ffmpeg.Stdout,
+	}
+	if offset > 0 {
+		decodeOpts.StartTime = fmt.Sprintf("%.3f", offset.Seconds())
+	}
+
+	c.offset = offset
+	c.packets.Store(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	p := &pipeline{
+		reader:  c.source.newReader(),
+		decoder: ffmpeg.New(decodeOpts),
+		encoder: ffmpeg.New(c.opts).WithCodec(&ogg.Decoder{}),
+		pw:      pw,
+		cancel:  cancel,
+	}
+
+	if err := p.decoder.Open(p.reader); err != nil {
+		cancel()
+		_ = p.reader.Close()
+		return err
+	}
+
+	if err := p.encoder.Open(pr); err != nil {
+		cancel()
+		_ = p.decoder.Close()
+		_ = p.reader.Close()
+		return err
+	}
+
+	c.pipeline = p
+	go c.pump(ctx, p.decoder, pw, c.frequency)
+
+	return nil
+}
+
+// closePipeline stops the current pipeline. The caller is expected to hold c.mu.
+func (c *trackCodec) closePipeline() error {
+	if c.pipeline == nil {
+		return nil
+	}
+
+	p := c.pipeline
+	c.pipeline = nil
+
+	p.cancel()
+	_ = p.pw.CloseWithError(io.ErrClosedPipe)
+	err := p.encoder.Close()
+	_ = p.decoder.Close()
+	_ = p.reader.Close()
+
+	return err
+}
+
+// pump moves PCM from the decoder to the encoder, resampling it to frequency and running it through the audio filter
+// on the way. It stays at most pumpLead ahead of what has actually been read out of the encoder.
+func (c *trackCodec) pump(ctx context.Context, decoder io.Reader, pw *io.PipeWriter, frequency int) {
+	in := make(chan [][]float32, 10)
+	var readErr error
+
+	go func() {
+		defer close(in)
+
+		buf := make([]byte, pcmFrameBytes)
+		for {
+			n, err := io.ReadFull(decoder, buf)
+			if n > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case in <- decodePCM(buf[:n]):
+				}
+			}
+
+			if err != nil {
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	// The resampler passes samples straight through when the rates match.
+	resampler := NewResampler(in, discordFrequency, frequency, resampleQuality)
+	go resampler.Resample()
+
+	var pumped time.Duration
+	for samples := range resampler.SamplesOut() {
+		c.filter.process(samples)
+
+		if _, err := pw.Write(encodePCM(samples)); err != nil {
+			// Drain so the resampler and reader can exit
+			for range resampler.SamplesOut() {
+			}
+			return
+		}
+
+		pumped += time.Duration(len(samples)) * time.Second / discordFrequency
+
+		for pumped-time.Duration(c.packets.Load())*packetDuration > pumpLead {
+			select {
+			case <-ctx.Done():
+				for range resampler.SamplesOut() {
+				}
+				return
+			case <-time.After(packetDuration):
+			}
+		}
+	}
+
+	// in is closed by the time SamplesOut is, so readErr is safe to read here.
+	if readErr != nil {
+		_ = pw.CloseWithError(readErr)
+	} else {
+		_ = pw.Close()
+	}
+}
+
+// pcmFrameBytes is the size of one packetDuration of raw s16le PCM.
+const pcmFrameBytes = discordFrequency * pcmChannels * 2 * int(packetDuration/time.Millisecond) / 1000
+
+// pcmFormat implements ffmpeg.Coder for raw signed 16-bit little endian PCM. It works as both a Decoder and Encoder.
+type pcmFormat struct{}
+
+func (p pcmFormat) Name() []string {
+	return []string{"-f", "s16le"}
+}
+
+func (p pcmFormat) Format() string {
+	return "s16le"
+}
+
+func (p pcmFormat) Args() []string {
+	return []string{"-ar", fmt.Sprintf("%d", discordFrequency), "-ac", fmt.Sprintf("%d", pcmChannels)}
+}
+
+// decodePCM converts interleaved s16le PCM into samples of [channel]float32 in the range [-1, 1).
+func decodePCM(b []byte) [][]float32 {
+	samples := make([][]float32, len(b)/(2*pcmChannels))
+	for i := range samples {
+		samples[i] = make([]float32, pcmChannels)
+		for ch := range samples[i] {
+			v := int16(binary.LittleEndian.Uint16(b[(i*pcmChannels+ch)*2:]))
+			samples[i][ch] = float32(v) / 32768
+		}
+	}
+
+	return samples
+}
+
+// encodePCM is the inverse of decodePCM. Values outside [-1, 1] are clipped.
+func encodePCM(samples [][]float32) []byte {
+	b := make([]byte, len(samples)*pcmChannels*2)
+	for i, sample := range samples {
+		for ch := 0; ch < pcmChannels && ch < len(sample); ch++ {
+			v := math.Max(-1, math.Min(1, float64(sample[ch])))
+			binary.LittleEndian.PutUint16(b[(i*pcmChannels+ch)*2:], uint16(int16(math.Round(v*32767))))
+		}
+	}
+
+	return b
+}
+
+// maxSourceMemory is how much of a playable a sourceBuffer keeps in memory. Anything past it is spilled to a temporary
+// file, so long local files don't have to be held in memory in full.
+const maxSourceMemory = 16 << 20
+
+// sourceBuffer reads from r in the background, keeping everything read so that it can be read again from the beginning
+// by any number of sourceReader(s). The first maxSourceMemory bytes are kept in memory and the rest in a temporary file.
+type sourceBuffer struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	data  []byte
+	spill *os.File
+	// size is how much has been read from r in total, including what was spilled.
+	size int64
+	// err is set once r is exhausted or the buffer is closed.
+	err error
+}
+
+func newSourceBuffer(r io.Reader) *sourceBuffer {
+	s := &sourceBuffer{}
+	s.cond = sync.NewCond(&s.mu)
+
+	go s.fill(r)
+
+	return s
+}
+
+func (s *sourceBuffer) fill(r io.Reader) {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := r.Read(buf)
+
+		s.mu.Lock()
+		if s.err != nil {
+			s.mu.Unlock()
+			return
+		}
+		if werr := s.write(buf[:n]); werr != nil {
+			err = werr
+		}
+		if err != nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+		s.cond.Broadcast()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write appends b to the buffer, spilling it to disk once the buffer is full. The caller is expected to hold s.mu.
+func (s *sourceBuffer) write(b []byte) error {
+	if s.spill == nil && len(s.data)+len(b) <= maxSourceMemory {
+		s.data = append(s.data, b...)
+		s.size += int64(len(b))
+		return nil
+	}
+
+	if s.spill == nil {
+		f, err := os.CreateTemp("", "eris-source-*")
+		if err != nil {
+			return err
+		}
+		s.spill = f
+	}
+
+	n, err := s.spill.Write(b)
+	s.size += int64(n)
+
+	return err
+}
+
+func (s *sourceBuffer) close() {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = io.ErrClosedPipe
+	}
+	if s.spill != nil {
+		_ = s.spill.Close()
+		_ = os.Remove(s.spill.Name())
+		s.spill = nil
+	}
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+func (s *sourceBuffer) newReader() *sourceReader {
+	return &sourceReader{source: s}
+}
+
+type sourceReader struct {
+	source *sourceBuffer
+	pos    int64
+	closed bool
+}
+
+// Read blocks until there is unread data in the source, or the source is exhausted.
+func (r *sourceReader) Read(b []byte) (int, error) {
+	s := r.source
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for !r.closed && r.pos >= s.size && s.err == nil {
+		s.cond.Wait()
+	}
+
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if r.pos >= s.size {
+		return 0, s.err
+	}
+
+	if r.pos < int64(len(s.data)) {
+		n := copy(b, s.data[r.pos:])
+		r.pos += int64(n)
+		return n, nil
+	}
+
+	// Everything past the in memory part is in the spill file, which is gone once the buffer has been closed
+	if s.spill == nil {
+		return 0, s.err
+	}
+
+	b = b[:min(int64(len(b)), s.size-r.pos)]
+	n, err := s.spill.ReadAt(b, r.pos-int64(len(s.data)))
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (r *sourceReader) Close() error {
+	r.source.mu.Lock()
+	r.closed = true
+	r.source.mu.Unlock()
+	r.source.cond.Broadcast()
+
+	return nil
+}
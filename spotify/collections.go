@@ -0,0 +1,112 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/apollo/spotify"
+	"github.com/olympus-go/eris/utils"
+)
+
+// collection is a playlist, album or artist whose tracks can be queued all at once.
+type collection struct {
+	resourceType spotify.ResourceType
+	name         string
+	image        string
+	trackIds     []string
+}
+
+// prompt returns the question asked before queueing the collection.
+func (c collection) prompt() string {
+	switch c.resourceType {
+	case spotify.AlbumResourceType:
+		return fmt.Sprintf(albumQueryStr, c.name, c.image)
+	case spotify.ArtistResourceType:
+		return fmt.Sprintf(artistQueryStr, c.name, c.image)
+	default:
+		return fmt.Sprintf(playlistQueryStr, c.name, c.image)
+	}
+}
+
+// queued returns the message sent once the collection has been queued.
+func (c collection) queued() string {
+	switch c.resourceType {
+	case spotify.AlbumResourceType:
+		return fmt.Sprintf("Album `%s` added to queue.", c.name)
+	case spotify.ArtistResourceType:
+		return fmt.Sprintf("Top tracks of `%s` added to queue.", c.name)
+	default:
+		return fmt.Sprintf("Playlist `%s` added to queue.", c.name)
+	}
+}
+
+// collectionButtons returns the yes/no/shuffle buttons asking whether a collection should be queued.
+func collectionButtons(uid string) discordgo.ActionsRow {
+	yesButton := utils.Button().Label("Yes").Id("spotify_playlist_yes_" + uid).Build()
+	noButton := utils.Button().Style(discordgo.SecondaryButton).Label("No").Id("spotify_playlist_no_" + uid).Build()
+	shuffleButton := utils.Button().Label("Shuffle").Id("spotify_playlist_shuffle_" + uid).Build()
+
+	return utils.ActionsRow().Button(yesButton).Button(noButton).Button(shuffleButton).Build()
+}
+
+// getCollection looks up the playlist, album or artist that link points to. Albums keep their track order, and artists
+// are represented by their top tracks.
+func getCollection(s *spotify.Session, web *webApi, uri spotify.Uri, link string) (collection, error) {
+	switch uri.Authority {
+	case spotify.PlaylistResourceType:
+		playlists, err := s.Search(link).Limit(1).Playlists()
+		if err != nil {
+			return collection{}, err
+		} else if len(playlists) == 0 {
+			return collection{}, errors.New("playlist not found")
+		}
+
+		return collection{
+			resourceType: uri.Authority,
+			name:         playlists[0].Name(),
+			image:        playlists[0].Image(),
+			trackIds:     playlists[0].TrackIds(),
+		}, nil
+	case spotify.ArtistResourceType:
+		artist, err := s.GetArtistById(uri.Path)
+		if err != nil {
+			return collection{}, err
+		}
+
+		return collection{
+			resourceType: uri.Authority,
+			name:         artist.Name(),
+			image:        artist.Image(),
+			trackIds:     artist.TopTrackIds(),
+		}, nil
+	case spotify.AlbumResourceType:
+		return getAlbum(web, uri.Path)
+	default:
+		return collection{}, fmt.Errorf("unsupported resource type %s", uri.Authority)
+	}
+}
+
+// getAlbum looks up the album with the given id. spotify.Session doesn't support albums, so this goes through the web
+// API instead.
+func getAlbum(web *webApi, id string) (collection, error) {
+	album, err := web.album(id)
+	if err != nil {
+		return collection{}, err
+	}
+
+	c := collection{
+		resourceType: spotify.AlbumResourceType,
+		name:         album.Name,
+	}
+
+	if len(album.Images) > 0 {
+		c.image = album.Images[0].Url
+	}
+
+	for _, t := range album.Tracks.Items {
+		c.trackIds = append(c.trackIds, t.Id)
+	}
+
+	return c, nil
+}
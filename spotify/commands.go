@@ -12,10 +12,11 @@ func (p *Plugin) playCommand() *discordgo.ApplicationCommandOption {
 		Type:        discordgo.ApplicationCommandOptionSubCommand,
 		Options: []*discordgo.ApplicationCommandOption{
 			{
-				Name:        p.config.PlayCommand.QueryOption.Alias,
-				Description: p.config.PlayCommand.QueryOption.Description,
-				Type:        discordgo.ApplicationCommandOptionString,
-				Required:    true,
+				Name:         p.config.PlayCommand.QueryOption.Alias,
+				Description:  p.config.PlayCommand.QueryOption.Description,
+				Type:         discordgo.ApplicationCommandOptionString,
+				Required:     true,
+				Autocomplete: true,
 			},
 			{
 				Name:        p.config.PlayCommand.PositionOption.Alias,
@@ -169,3 +170,305 @@ func (p *Plugin) shuffleCommand() *discordgo.ApplicationCommandOption {
 		Type:        discordgo.ApplicationCommandOptionSubCommand,
 	}
 }
+
+func (p *Plugin) seekCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.SeekCommand.Alias,
+		Description: p.config.SeekCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        p.config.SeekCommand.PositionOption.Alias,
+				Description: p.config.SeekCommand.PositionOption.Description,
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    true,
+			},
+		},
+	}
+}
+
+func (p *Plugin) volumeCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.VolumeCommand.Alias,
+		Description: p.config.VolumeCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        p.config.VolumeCommand.PercentOption.Alias,
+				Description: p.config.VolumeCommand.PercentOption.Description,
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    utils.PointerTo(0.0),
+				MaxValue:    200,
+			},
+		},
+	}
+}
+
+func (p *Plugin) loopCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.LoopCommand.Alias,
+		Description: p.config.LoopCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        p.config.LoopCommand.ModeOption.Alias,
+				Description: p.config.LoopCommand.ModeOption.Description,
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    true,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{
+						Name:  loopOff,
+						Value: loopOff,
+					},
+					{
+						Name:  loopTrack,
+						Value: loopTrack,
+					},
+					{
+						Name:  loopQueue,
+						Value: loopQueue,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (p *Plugin) guildPlaylistCommand() *discordgo.ApplicationCommandOption {
+	config := p.config.GuildPlaylistCommand
+
+	nameOption := &discordgo.ApplicationCommandOption{
+		Name:         config.NameOption.Alias,
+		Description:  config.NameOption.Description,
+		Type:         discordgo.ApplicationCommandOptionString,
+		Required:     true,
+		MaxLength:    100,
+		Autocomplete: true,
+	}
+
+	return &discordgo.ApplicationCommandOption{
+		Name:        config.Alias,
+		Description: config.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        config.SaveSubcommand.Alias,
+				Description: config.SaveSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        config.NameOption.Alias,
+						Description: config.NameOption.Description,
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+						MaxLength:   100,
+					},
+				},
+			},
+			{
+				Name:        config.LoadSubcommand.Alias,
+				Description: config.LoadSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     []*discordgo.ApplicationCommandOption{nameOption},
+			},
+			{
+				Name:        config.ListSubcommand.Alias,
+				Description: config.ListSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        config.DeleteSubcommand.Alias,
+				Description: config.DeleteSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     []*discordgo.ApplicationCommandOption{nameOption},
+			},
+			{
+				Name:        config.AddSubcommand.Alias,
+				Description: config.AddSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					nameOption,
+					{
+						Name:        config.QueryOption.Alias,
+						Description: config.QueryOption.Description,
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        config.RemoveSubcommand.Alias,
+				Description: config.RemoveSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					nameOption,
+					{
+						Name:        config.PositionOption.Alias,
+						Description: config.PositionOption.Description,
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Required:    true,
+						MinValue:    utils.PointerTo(1.0),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (p *Plugin) autoplayCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.AutoplayCommand.Alias,
+		Description: p.config.AutoplayCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        p.config.AutoplayCommand.EnabledOption.Alias,
+				Description: p.config.AutoplayCommand.EnabledOption.Description,
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Required:    true,
+			},
+		},
+	}
+}
+
+func (p *Plugin) filesCommand() *discordgo.ApplicationCommandOption {
+	config := p.config.FilesCommand
+
+	nameOption := &discordgo.ApplicationCommandOption{
+		Name:         config.NameOption.Alias,
+		Description:  config.NameOption.Description,
+		Type:         discordgo.ApplicationCommandOptionString,
+		Required:     true,
+		Autocomplete: true,
+	}
+
+	return &discordgo.ApplicationCommandOption{
+		Name:        config.Alias,
+		Description: config.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        config.UploadSubcommand.Alias,
+				Description: config.UploadSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        config.FileOption.Alias,
+						Description: config.FileOption.Description,
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        config.ListSubcommand.Alias,
+				Description: config.ListSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        config.RenameSubcommand.Alias,
+				Description: config.RenameSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					nameOption,
+					{
+						Name:        config.NewNameOption.Alias,
+						Description: config.NewNameOption.Description,
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        config.DeleteSubcommand.Alias,
+				Description: config.DeleteSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     []*discordgo.ApplicationCommandOption{nameOption},
+			},
+			{
+				Name:        config.InfoSubcommand.Alias,
+				Description: config.InfoSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     []*discordgo.ApplicationCommandOption{nameOption},
+			},
+			{
+				Name:        config.PublishSubcommand.Alias,
+				Description: config.PublishSubcommand.Description,
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     []*discordgo.ApplicationCommandOption{nameOption},
+			},
+		},
+	}
+}
+
+func (p *Plugin) exportCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.ExportCommand.Alias,
+		Description: p.config.ExportCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+	}
+}
+
+func (p *Plugin) importCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.ImportCommand.Alias,
+		Description: p.config.ImportCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        p.config.ImportCommand.FileOption.Alias,
+				Description: p.config.ImportCommand.FileOption.Description,
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Required:    true,
+			},
+		},
+	}
+}
+
+func (p *Plugin) moveCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.MoveCommand.Alias,
+		Description: p.config.MoveCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        p.config.MoveCommand.FromOption.Alias,
+				Description: p.config.MoveCommand.FromOption.Description,
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    true,
+				MinValue:    utils.PointerTo(1.0),
+			},
+			{
+				Name:        p.config.MoveCommand.ToOption.Alias,
+				Description: p.config.MoveCommand.ToOption.Description,
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    utils.PointerTo(1.0),
+			},
+			{
+				Name:        p.config.MoveCommand.CountOption.Alias,
+				Description: p.config.MoveCommand.CountOption.Description,
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    false,
+				MinValue:    utils.PointerTo(1.0),
+			},
+		},
+	}
+}
+
+func (p *Plugin) banCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.BanCommand.Alias,
+		Description: p.config.BanCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+	}
+}
+
+func (p *Plugin) unbanCommand() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        p.config.UnbanCommand.Alias,
+		Description: p.config.UnbanCommand.Description,
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+	}
+}
@@ -9,15 +9,34 @@ import (
 var DefaultConfigStr string
 
 type Config struct {
-	Alias               string   `json:"Alias"`
-	Description         string   `json:"Description"`
-	AdminIds            []string `json:"AdminIds"`
-	SpotifyCallbackUrl  string   `json:"-"`
-	SpotifyClientId     string   `json:"-"`
-	SpotifyClientSecret string   `json:"-"`
-	RestrictSkips       string   `json:"RestrictSkips"`
-	BannedTracks        []string `json:"BannedTracks"`
-	GlobalResponses     struct {
+	Alias                 string   `json:"Alias"`
+	Description           string   `json:"Description"`
+	AdminIds              []string `json:"AdminIds"`
+	SpotifyCallbackUrl    string   `json:"-"`
+	SpotifyClientId       string   `json:"-"`
+	SpotifyClientSecret   string   `json:"-"`
+	RestrictSkips         string   `json:"RestrictSkips"`
+	VoteSkips             string   `json:"VoteSkips"`
+	VoteSkipFraction      string   `json:"VoteSkipFraction"`
+	NowPlayingPanel       string   `json:"NowPlayingPanel"`
+	FairQueue             string   `json:"FairQueue"`
+	MaxQueuedPerRequester string   `json:"MaxQueuedPerRequester"`
+	MaxQueueLength        string   `json:"MaxQueueLength"`
+	MaxTrackDuration      string   `json:"MaxTrackDuration"`
+	PlaylistDuplicates    string   `json:"PlaylistDuplicates"`
+	BannedTracks          []string `json:"BannedTracks"`
+	BannedArtists         []string `json:"BannedArtists"`
+	BannedAlbums          []string `json:"BannedAlbums"`
+	BannedPatterns        []string `json:"BannedPatterns"`
+	DefaultVolume         string   `json:"DefaultVolume"`
+	LoudnessTarget        string   `json:"LoudnessTarget"`
+	Autoplay              string   `json:"Autoplay"`
+	AutoplaySeeds         string   `json:"AutoplaySeeds"`
+	MessageTriggers       string   `json:"MessageTriggers"`
+	MaxUploadSize         string   `json:"MaxUploadSize"`
+	MaxLibrarySize        string   `json:"MaxLibrarySize"`
+	GlobalLibrary         string   `json:"GlobalLibrary"`
+	GlobalResponses       struct {
 		GenericSuccess   string `json:"GenericSuccess"`
 		GenericError     string `json:"GenericError"`
 		NotInVoice       string `json:"NotInVoice"`
@@ -32,12 +51,20 @@ type Config struct {
 		PositionOption CommandOptionConfig `json:"PositionOption"`
 		RemixOption    CommandOptionConfig `json:"RemixOption"`
 		Responses      struct {
-			SongPrompt       string `json:"SongPrompt"`
-			ListNotAvailable string `json:"ListNotAvailable"`
-			NoTracksFound    string `json:"NoTracksFound"`
-			EndOfList        string `json:"EndOfList"`
-			LoadingPlaylist  string `json:"LoadingPlaylist"`
-			BannedTrack      string `json:"BannedTrack"`
+			SongPrompt            string `json:"SongPrompt"`
+			ListNotAvailable      string `json:"ListNotAvailable"`
+			NoTracksFound         string `json:"NoTracksFound"`
+			EndOfList             string `json:"EndOfList"`
+			LoadingPlaylist       string `json:"LoadingPlaylist"`
+			BannedTrack           string `json:"BannedTrack"`
+			QueueFull             string `json:"QueueFull"`
+			RequesterLimitReached string `json:"RequesterLimitReached"`
+			TrackTooLong          string `json:"TrackTooLong"`
+			PlaylistLimited       string `json:"PlaylistLimited"`
+			DuplicatePrompt       string `json:"DuplicatePrompt"`
+			DuplicateCancelled    string `json:"DuplicateCancelled"`
+			PlaylistDuplicates    string `json:"PlaylistDuplicates"`
+			PlaylistSkipped       string `json:"PlaylistSkipped"`
 		} `json:"Responses"`
 	} `json:"PlayCommand"`
 	QueueCommand struct {
@@ -80,7 +107,13 @@ type Config struct {
 		Alias       string `json:"Alias"`
 		Description string `json:"Description"`
 		Responses   struct {
-			NextSuccess string `json:"NextSuccess"`
+			NextSuccess  string `json:"NextSuccess"`
+			VotePrompt   string `json:"VotePrompt"`
+			VoteRecorded string `json:"VoteRecorded"`
+			VotePassed   string `json:"VotePassed"`
+			AlreadyVoted string `json:"AlreadyVoted"`
+			VoteExpired  string `json:"VoteExpired"`
+			NotListening string `json:"NotListening"`
 		} ` json:"Responses"`
 	} `json:"NextCommand"`
 	PreviousCommand struct {
@@ -129,6 +162,136 @@ type Config struct {
 		Alias       string `json:"Alias"`
 		Description string `json:"Description"`
 	} `json:"ShuffleCommand"`
+	SeekCommand struct {
+		Alias          string              `json:"Alias"`
+		Description    string              `json:"Description"`
+		PositionOption CommandOptionConfig `json:"PositionOption"`
+		Responses      struct {
+			InvalidPosition string `json:"InvalidPosition"`
+			SeekSuccess     string `json:"SeekSuccess"`
+		} `json:"Responses"`
+	} `json:"SeekCommand"`
+	VolumeCommand struct {
+		Alias         string              `json:"Alias"`
+		Description   string              `json:"Description"`
+		PercentOption CommandOptionConfig `json:"PercentOption"`
+		Responses     struct {
+			VolumeSuccess string `json:"VolumeSuccess"`
+			CurrentVolume string `json:"CurrentVolume"`
+		} `json:"Responses"`
+	} `json:"VolumeCommand"`
+	LoopCommand struct {
+		Alias       string              `json:"Alias"`
+		Description string              `json:"Description"`
+		ModeOption  CommandOptionConfig `json:"ModeOption"`
+		Responses   struct {
+			LoopOff   string `json:"LoopOff"`
+			LoopTrack string `json:"LoopTrack"`
+			LoopQueue string `json:"LoopQueue"`
+		} `json:"Responses"`
+	} `json:"LoopCommand"`
+	MoveCommand struct {
+		Alias       string              `json:"Alias"`
+		Description string              `json:"Description"`
+		FromOption  CommandOptionConfig `json:"FromOption"`
+		ToOption    CommandOptionConfig `json:"ToOption"`
+		CountOption CommandOptionConfig `json:"CountOption"`
+		Responses   struct {
+			InvalidPosition string `json:"InvalidPosition"`
+			MoveSuccess     string `json:"MoveSuccess"`
+		} `json:"Responses"`
+	} `json:"MoveCommand"`
+	BanCommand struct {
+		Alias       string `json:"Alias"`
+		Description string `json:"Description"`
+		Responses   struct {
+			NotBannable  string `json:"NotBannable"`
+			BanSuccess   string `json:"BanSuccess"`
+			UnbanSuccess string `json:"UnbanSuccess"`
+		} `json:"Responses"`
+	} `json:"BanCommand"`
+	UnbanCommand struct {
+		Alias       string `json:"Alias"`
+		Description string `json:"Description"`
+	} `json:"UnbanCommand"`
+	GuildPlaylistCommand struct {
+		Alias            string              `json:"Alias"`
+		Description      string              `json:"Description"`
+		SaveSubcommand   CommandOptionConfig `json:"SaveSubcommand"`
+		LoadSubcommand   CommandOptionConfig `json:"LoadSubcommand"`
+		ListSubcommand   CommandOptionConfig `json:"ListSubcommand"`
+		DeleteSubcommand CommandOptionConfig `json:"DeleteSubcommand"`
+		AddSubcommand    CommandOptionConfig `json:"AddSubcommand"`
+		RemoveSubcommand CommandOptionConfig `json:"RemoveSubcommand"`
+		NameOption       CommandOptionConfig `json:"NameOption"`
+		QueryOption      CommandOptionConfig `json:"QueryOption"`
+		PositionOption   CommandOptionConfig `json:"PositionOption"`
+		Responses        struct {
+			NotFound        string `json:"NotFound"`
+			AlreadyExists   string `json:"AlreadyExists"`
+			EmptyPlaylist   string `json:"EmptyPlaylist"`
+			NoPlaylists     string `json:"NoPlaylists"`
+			InvalidPosition string `json:"InvalidPosition"`
+			NotAllowed      string `json:"NotAllowed"`
+			SaveSuccess     string `json:"SaveSuccess"`
+			DeleteSuccess   string `json:"DeleteSuccess"`
+			AddSuccess      string `json:"AddSuccess"`
+			RemoveSuccess   string `json:"RemoveSuccess"`
+		} `json:"Responses"`
+	} `json:"GuildPlaylistCommand"`
+	AutoplayCommand struct {
+		Alias         string              `json:"Alias"`
+		Description   string              `json:"Description"`
+		EnabledOption CommandOptionConfig `json:"EnabledOption"`
+		Responses     struct {
+			AutoplayOn  string `json:"AutoplayOn"`
+			AutoplayOff string `json:"AutoplayOff"`
+		} `json:"Responses"`
+	} `json:"AutoplayCommand"`
+	ExportCommand struct {
+		Alias       string `json:"Alias"`
+		Description string `json:"Description"`
+		Responses   struct {
+			ExportSuccess string `json:"ExportSuccess"`
+		} `json:"Responses"`
+	} `json:"ExportCommand"`
+	FilesCommand struct {
+		Alias             string              `json:"Alias"`
+		Description       string              `json:"Description"`
+		UploadSubcommand  CommandOptionConfig `json:"UploadSubcommand"`
+		ListSubcommand    CommandOptionConfig `json:"ListSubcommand"`
+		RenameSubcommand  CommandOptionConfig `json:"RenameSubcommand"`
+		DeleteSubcommand  CommandOptionConfig `json:"DeleteSubcommand"`
+		InfoSubcommand    CommandOptionConfig `json:"InfoSubcommand"`
+		PublishSubcommand CommandOptionConfig `json:"PublishSubcommand"`
+		FileOption        CommandOptionConfig `json:"FileOption"`
+		NameOption        CommandOptionConfig `json:"NameOption"`
+		NewNameOption     CommandOptionConfig `json:"NewNameOption"`
+		Responses         struct {
+			NotFound        string `json:"NotFound"`
+			AlreadyExists   string `json:"AlreadyExists"`
+			InvalidName     string `json:"InvalidName"`
+			NotAudio        string `json:"NotAudio"`
+			TooLarge        string `json:"TooLarge"`
+			LibraryFull     string `json:"LibraryFull"`
+			NoGlobalLibrary string `json:"NoGlobalLibrary"`
+			NoFiles         string `json:"NoFiles"`
+			UploadSuccess   string `json:"UploadSuccess"`
+			RenameSuccess   string `json:"RenameSuccess"`
+			DeleteSuccess   string `json:"DeleteSuccess"`
+			PublishSuccess  string `json:"PublishSuccess"`
+		} `json:"Responses"`
+	} `json:"FilesCommand"`
+	ImportCommand struct {
+		Alias       string              `json:"Alias"`
+		Description string              `json:"Description"`
+		FileOption  CommandOptionConfig `json:"FileOption"`
+		Responses   struct {
+			ImportSuccess string `json:"ImportSuccess"`
+			InvalidFile   string `json:"InvalidFile"`
+			FailedLines   string `json:"FailedLines"`
+		} `json:"Responses"`
+	} `json:"ImportCommand"`
 }
 
 type CommandOptionConfig struct {
@@ -0,0 +1,79 @@
+package spotify
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/apollo"
+	"github.com/olympus-go/apollo/spotify"
+)
+
+// Policies for tracks in a playlist that are already queued.
+const (
+	duplicatesAllow = "allow"
+	duplicatesWarn  = "warn"
+	duplicatesSkip  = "skip"
+)
+
+// playlistDuplicatePolicy returns the configured policy for duplicate playlist tracks, falling back to warn if it isn't
+// one of the known ones.
+func (p *Plugin) playlistDuplicatePolicy() string {
+	switch policy := strings.ToLower(p.config.PlaylistDuplicates); policy {
+	case duplicatesAllow, duplicatesWarn, duplicatesSkip:
+		return policy
+	default:
+		return duplicatesWarn
+	}
+}
+
+// isQueued reports whether the same song as playable is already coming up in the queue. Spotify tracks are compared by
+// id and local files by path, so the same song queued by different people still counts.
+func (s *session) isQueued(playable apollo.Playable) bool {
+	key := duplicateKey(playable)
+	if key == "" {
+		return false
+	}
+
+	for _, queued := range s.player.List(false) {
+		if duplicateKey(queued) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// duplicateKey returns what identifies the song behind playable, or "" if it can't be identified.
+func duplicateKey(playable apollo.Playable) string {
+	switch t := playable.(type) {
+	case *track:
+		return "spotify:" + t.Id()
+	case spotify.Track:
+		return "spotify:" + t.Id()
+	case *localFile:
+		if path := t.Metadata()["path"]; path != "" {
+			return "file:" + path
+		}
+	}
+
+	return ""
+}
+
+func duplicateButtons(uid string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Add anyway",
+					Style:    discordgo.PrimaryButton,
+					CustomID: "spotify_play_anyway_" + uid,
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: "spotify_play_cancel_" + uid,
+				},
+			},
+		},
+	}
+}
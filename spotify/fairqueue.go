@@ -0,0 +1,142 @@
+package spotify
+
+import (
+	"strings"
+
+	"github.com/olympus-go/apollo"
+	"golang.org/x/exp/slices"
+)
+
+// rebalanceQueue reorders the session's upcoming tracks so every requester gets a turn, if fair queueing is enabled.
+// It should be called whenever tracks are added to or removed from the queue.
+func (p *Plugin) rebalanceQueue(s *session) {
+	if strings.ToLower(p.config.FairQueue) != "true" {
+		return
+	}
+
+	s.rebalance()
+}
+
+// waitForQueue blocks until nothing is reordering the queue. It's called before the player moves on to its next track.
+func (s *session) waitForQueue() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+}
+
+// pin keeps playable at the position it was inserted at when the queue gets rebalanced.
+func (s *session) pin(playable apollo.Playable) {
+	s.pinned.Set(playable, true)
+}
+
+// shuffle shuffles the upcoming tracks. Shuffling overrides any positions tracks were inserted at, so pins are cleared.
+// With fair queueing enabled the queue should be rebalanced afterwards, which keeps the shuffled order within each
+// requester's tracks.
+func (s *session) shuffle() {
+	s.player.Shuffle(false)
+	s.pinned.Empty()
+}
+
+// rebalance reorders the upcoming tracks round-robin by requester. Pinned tracks keep their position and everything else
+// is arranged around them.
+//
+// apollo.Player doesn't allow reordering its queue directly, so tracks are moved one at a time by inserting them at
+// their new position before removing them from their old one. That way the queue is never missing anything that the
+// player could try to start playing mid rebalance. The player can't move on from a track while s.queueMu is held (see
+// waitForQueue), and if it starts playing anyway because it was idle the rebalance stops where it is, since every
+// position it worked out is off by one from then on.
+func (s *session) rebalance() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	cursor := s.player.Cursor()
+	// List returns the player's own backing slice, which changes underneath us as tracks get moved
+	upcoming := slices.Clone(s.player.List(false))
+
+	requesters := make([]string, len(upcoming))
+	pinned := make([]bool, len(upcoming))
+	for index, playable := range upcoming {
+		requesters[index] = playable.Metadata()["requesterId"]
+		pinned[index], _ = s.pinned.Get(playable)
+	}
+
+	// Forget pins on anything that isn't coming up anymore
+	for _, playable := range s.pinned.Keys() {
+		if !slices.Contains(upcoming, playable) {
+			s.pinned.Delete(playable)
+		}
+	}
+
+	var lastRequester string
+	if np, ok := s.player.NowPlaying(); ok {
+		lastRequester = np.Metadata()["requesterId"]
+	}
+
+	for position, index := range fairOrder(requesters, pinned, lastRequester) {
+		want := upcoming[index]
+		if s.player.Get(cursor+position) == want {
+			continue
+		}
+
+		if s.player.Cursor() != cursor {
+			return
+		}
+
+		for current := cursor + position + 1; current < cursor+len(upcoming); current++ {
+			if s.player.Get(current) == want {
+				s.player.Insert(cursor+position, want)
+				s.player.Remove(current + 1)
+				break
+			}
+		}
+	}
+}
+
+// fairOrder returns the order the tracks should be played in as indices into requesters, where requesters holds the
+// requester of each upcoming track. Pinned tracks keep their index. The rest are interleaved round-robin by requester
+// in order of their first track, with lastRequester (whoever requested what's currently playing) going last. Each
+// requester's own tracks stay in the order they were queued.
+func fairOrder(requesters []string, pinned []bool, lastRequester string) []int {
+	var order []string
+	groups := make(map[string][]int)
+	for index, requester := range requesters {
+		if pinned[index] {
+			continue
+		}
+
+		if _, ok := groups[requester]; !ok {
+			order = append(order, requester)
+		}
+		groups[requester] = append(groups[requester], index)
+	}
+
+	for index, requester := range order {
+		if requester == lastRequester {
+			order = append(append(order[:index:index], order[index+1:]...), requester)
+			break
+		}
+	}
+
+	var interleaved []int
+	for added := true; added; {
+		added = false
+		for _, requester := range order {
+			if len(groups[requester]) > 0 {
+				interleaved = append(interleaved, groups[requester][0])
+				groups[requester] = groups[requester][1:]
+				added = true
+			}
+		}
+	}
+
+	result := make([]int, 0, len(requesters))
+	for index := range requesters {
+		if pinned[index] {
+			result = append(result, index)
+		} else {
+			result = append(result, interleaved[0])
+			interleaved = interleaved[1:]
+		}
+	}
+
+	return result
+}
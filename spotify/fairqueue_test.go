@@ -0,0 +1,37 @@
+package spotify
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestFairOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		requesters    []string
+		pinned        []bool
+		lastRequester string
+		want          []int
+	}{
+		{"empty", nil, nil, "", []int{}},
+		{"single requester", []string{"a", "a", "a"}, []bool{false, false, false}, "", []int{0, 1, 2}},
+		{"single requester is last", []string{"a", "a", "a"}, []bool{false, false, false}, "a", []int{0, 1, 2}},
+		{"interleaved", []string{"a", "a", "b", "b"}, []bool{false, false, false, false}, "", []int{0, 2, 1, 3}},
+		{"uneven", []string{"a", "a", "a", "b"}, []bool{false, false, false, false}, "", []int{0, 3, 1, 2}},
+		{"last requester goes last", []string{"a", "a", "b"}, []bool{false, false, false}, "a", []int{2, 0, 1}},
+		{"rotates after last requester", []string{"a", "b", "c", "a"}, []bool{false, false, false, false}, "a", []int{1, 2, 0, 3}},
+		{"last requester not queued", []string{"a", "b"}, []bool{false, false}, "c", []int{0, 1}},
+		{"pinned keeps its index", []string{"a", "a", "a", "b"}, []bool{false, false, true, false}, "", []int{0, 3, 2, 1}},
+		{"pinned first", []string{"b", "a", "a", "b"}, []bool{true, false, false, false}, "", []int{0, 1, 3, 2}},
+		{"everything pinned", []string{"a", "a", "b"}, []bool{true, true, true}, "a", []int{0, 1, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := fairOrder(test.requesters, test.pinned, test.lastRequester); !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package spotify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/eolso/threadsafe"
+)
+
+// filesPageSize is how many files are listed on each page of the library.
+const filesPageSize = 15
+
+// filesView is the pager view for the library, alongside queueView and historyView.
+const filesView = "files"
+
+// maxArtworkSize is the largest embedded artwork that's attached to file info. It's only shown as a thumbnail, so
+// anything bigger is left off rather than uploaded every time.
+const maxArtworkSize = 2 << 20
+
+// maxNameAttempts is how many numbered names freeFilePath tries before giving up.
+const maxNameAttempts = 1000
+
+// artworkCacheSize is how many files' artwork is kept in memory, so paging back and forth doesn't re-read it.
+const artworkCacheSize = 16
+
+var (
+	errFileNotFound = errors.New("file not found")
+	errFileExists   = errors.New("file already exists")
+	errFileName     = errors.New("invalid file name")
+	errNotAudio     = errors.New("file isn't audio")
+	errFileTooLarge = errors.New("file is too large")
+	errLibraryFull  = errors.New("library is full")
+	errNoGlobal     = errors.New("global library is disabled")
+	errNoLibrary    = errors.New("no library outside of a guild")
+)
+
+// uploadsMu guards picking a name for an upload and moving it into place.
+var uploadsMu sync.Mutex
+
+// attachmentClient downloads attachments from discord. The timeout is generous enough for the largest uploads, but
+// stops a stalled download from holding up the handler forever.
+var attachmentClient = &http.Client{Timeout: 2 * time.Minute}
+
+// artworkCache holds the artwork most recently shown by fileInfo, keyed by the file's full path.
+var artworkCache = threadsafe.NewMap[string, cachedArtwork]()
+
+// cachedArtwork is the artwork read from a file, along with what the file looked like when it was read so changes are
+// picked up.
+type cachedArtwork struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+	mime    string
+}
+
+// validFileName reports whether name can be used as the name of a file in the library. Only bare file names are
+// allowed, so nothing can end up outside of the directory it's meant for.
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+// downloadToLibrary saves attachment to the top of the given library, then indexes it. The file is downloaded to a temporary
+// file first and only moved into place once it's been checked, so a failed or rejected upload never leaves anything
+// half written behind. If the name is already taken a number is added to it rather than overwriting anything.
+func (p *Plugin) downloadToLibrary(l *library, attachment *discordgo.MessageAttachment) (libraryEntry, error) {
+	if l == nil {
+		return libraryEntry{}, errNoLibrary
+	}
+
+	name := strings.TrimSpace(attachment.Filename)
+	if !validFileName(name) {
+		return libraryEntry{}, errFileName
+	}
+
+	// The size discord reports is checked up front so obviously oversized files aren't downloaded at all
+	maxSize := megabytes(p.config.MaxUploadSize)
+	if maxSize > 0 && int64(attachment.Size) > maxSize {
+		return libraryEntry{}, errFileTooLarge
+	}
+	if err := p.checkLibraryRoom(l, int64(attachment.Size)); err != nil {
+		return libraryEntry{}, err
+	}
+
+	resp, err := attachmentClient.Get(attachment.URL)
+	if err != nil {
+		return libraryEntry{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return libraryEntry{}, fmt.Errorf("unexpected status downloading %s: %s", name, resp.Status)
+	}
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return libraryEntry{}, err
+	}
+	if !isAudio(header[:n]) {
+		return libraryEntry{}, errNotAudio
+	}
+
+	tmp, err := os.CreateTemp(l.dir, ".upload-*.tmp")
+	if err != nil {
+		return libraryEntry{}, err
+	}
+	// Once the file has been moved into place the remove just fails
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	body := io.MultiReader(bytes.NewReader(header[:n]), resp.Body)
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	written, err := io.Copy(tmp, body)
+	if err != nil {
+		return libraryEntry{}, err
+	}
+	if maxSize > 0 && written > maxSize {
+		return libraryEntry{}, errFileTooLarge
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return libraryEntry{}, err
+	}
+	if err = tmp.Close(); err != nil {
+		return libraryEntry{}, err
+	}
+
+	// Picking a free name and moving the file there has to happen together, or two uploads could pick the same name. The
+	// room left is checked again here too, otherwise two uploads could both fit on their own but not together.
+	uploadsMu.Lock()
+	defer uploadsMu.Unlock()
+
+	if err = p.checkLibraryRoom(l, written); err != nil {
+		return libraryEntry{}, err
+	}
+
+	path, err := freeFilePath(l.dir, name)
+	if err != nil {
+		return libraryEntry{}, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return libraryEntry{}, err
+	}
+
+	return l.added(path), nil
+}
+
+// freeFilePath returns the path for name in dir, adding a number to it (e.g. "song (1).mp3") if it's already taken.
+// errFileExists is returned if none of the first maxNameAttempts names are free.
+func freeFilePath(dir string, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for n := 1; n <= maxNameAttempts; n++ {
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+	}
+
+	return "", errFileExists
+}
+
+// checkLibraryRoom returns errLibraryFull if adding size bytes would take l over MaxLibrarySize.
+func (p *Plugin) checkLibraryRoom(l *library, size int64) error {
+	limit := megabytes(p.config.MaxLibrarySize)
+	if limit > 0 && l.size()+size > limit {
+		return errLibraryFull
+	}
+
+	return nil
+}
+
+// megabytes parses a size in megabytes from the config into bytes. 0 is returned if it's unset or invalid, which
+// means there's no limit.
+func megabytes(s string) int64 {
+	mb, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(mb > 0) || math.IsInf(mb, 0) {
+		return 0
+	}
+
+	return int64(mb * (1 << 20))
+}
+
+// isAudio reports whether header, the start of a file, looks like a format ffmpeg can play audio from.
+func isAudio(header []byte) bool {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")),
+		bytes.HasPrefix(header, []byte("fLaC")),
+		bytes.HasPrefix(header, []byte("OggS")),
+		bytes.HasPrefix(header, []byte("wvpk")),
+		bytes.HasPrefix(header, []byte("MAC ")),
+		bytes.HasPrefix(header, []byte("#!AMR")),
+		bytes.HasPrefix(header, []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}):
+		return true
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WAVE",
+		len(header) >= 12 && string(header[:4]) == "FORM" && (string(header[8:12]) == "AIFF" || string(header[8:12]) == "AIFC"),
+		len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return true
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		// A bare mp3 or AAC frame, without any tags in front of it
+		return true
+	}
+
+	return false
+}
+
+// uploadOutcome describes how saving attachment went, for the user that uploaded it.
+func (p *Plugin) uploadOutcome(attachment *discordgo.MessageAttachment, entry libraryEntry, err error, logger *slog.Logger) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("`%s`: %s", attachment.Filename, p.fileErrorMessage(err, logger))
+	case entry.path != strings.TrimSpace(attachment.Filename):
+		return fmt.Sprintf("%s Saved `%s` as `%s`, since that name was already taken.",
+			p.config.FilesCommand.Responses.UploadSuccess, attachment.Filename, entry.ref())
+	default:
+		return fmt.Sprintf("%s Saved `%s`.", p.config.FilesCommand.Responses.UploadSuccess, entry.ref())
+	}
+}
+
+// renameLibraryFile renames the given guild's file called name to newName, keeping it in the same directory. Global
+// files can be renamed by marking name with globalFilePrefix. The renamed entry is returned.
+func (p *Plugin) renameLibraryFile(guildId string, name string, newName string) (libraryEntry, error) {
+	l, entry, ok := p.ownLocalFile(guildId, name)
+	if !ok {
+		return libraryEntry{}, errFileNotFound
+	}
+
+	newName = strings.TrimSpace(newName)
+	if !validFileName(newName) {
+		return libraryEntry{}, errFileName
+	}
+
+	// Same as uploads, checking the name is free and taking it has to happen together
+	uploadsMu.Lock()
+	defer uploadsMu.Unlock()
+
+	from := entry.fullPath()
+	to := filepath.Join(filepath.Dir(from), newName)
+	if _, err := os.Lstat(to); err == nil {
+		return libraryEntry{}, errFileExists
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return libraryEntry{}, err
+	}
+
+	if err := os.Rename(from, to); err != nil {
+		return libraryEntry{}, err
+	}
+	l.update(from)
+
+	return l.added(to), nil
+}
+
+// removeLibraryFile deletes the given guild's file called name. Global files can be deleted by marking name with
+// globalFilePrefix. The removed entry is returned.
+func (p *Plugin) removeLibraryFile(guildId string, name string) (libraryEntry, error) {
+	l, entry, ok := p.ownLocalFile(guildId, name)
+	if !ok {
+		return libraryEntry{}, errFileNotFound
+	}
+
+	if err := os.Remove(entry.fullPath()); err != nil {
+		return libraryEntry{}, err
+	}
+	l.update(entry.fullPath())
+
+	return entry, nil
+}
+
+// publishLibraryFile moves the given guild's file called name to the top of the global library, so every guild can play
+// it. The published entry is returned.
+func (p *Plugin) publishLibraryFile(guildId string, name string) (libraryEntry, error) {
+	global := p.globalLibrary()
+	if global == nil {
+		return libraryEntry{}, errNoGlobal
+	}
+
+	l := p.guildLibrary(guildId)
+	if l == nil {
+		return libraryEntry{}, errFileNotFound
+	}
+
+	entry, ok := l.find(name)
+	if !ok {
+		return libraryEntry{}, errFileNotFound
+	}
+
+	uploadsMu.Lock()
+	defer uploadsMu.Unlock()
+
+	if err := p.checkLibraryRoom(global, entry.size); err != nil {
+		return libraryEntry{}, err
+	}
+
+	from := entry.fullPath()
+	to := filepath.Join(global.dir, entry.name())
+	if _, err := os.Lstat(to); err == nil {
+		return libraryEntry{}, errFileExists
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return libraryEntry{}, err
+	}
+
+	if err := os.Rename(from, to); err != nil {
+		return libraryEntry{}, err
+	}
+	l.update(from)
+
+	return global.added(to), nil
+}
+
+// renderFilesPage renders the requested page of the files the given guild can play the same way renderPage does for
+// the queue. false is returned if there aren't any.
+func (p *Plugin) renderFilesPage(guildId string, page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent, bool) {
+	entries := p.listLocalFiles(guildId, "")
+	if len(entries) == 0 {
+		return nil, nil, false
+	}
+
+	pages := (len(entries) + filesPageSize - 1) / filesPageSize
+	page = min(max(page, 0), pages-1)
+	offset := page * filesPageSize
+
+	var lines []string
+	for index, entry := range entries[offset:min(offset+filesPageSize, len(entries))] {
+		line := fmt.Sprintf("`%d.` `%s`", offset+index+1, entry.ref())
+		if entry.title != "" {
+			line += fmt.Sprintf(" - %s by %s", entry.displayTitle(), entry.displayArtist())
+		}
+		if entry.duration > 0 {
+			line += fmt.Sprintf(" `%s`", entry.duration.Round(time.Second))
+		}
+
+		lines = append(lines, line)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Library",
+		Description: strings.Join(lines, "\n"),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d | %d files", page+1, pages, len(entries)),
+		},
+	}
+
+	row := discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			pageButton(filesView, "first", 0, "First", page > 0),
+			pageButton(filesView, "prev", page-1, "Prev", page > 0),
+			pageButton(filesView, "next", page+1, "Next", page < pages-1),
+			pageButton(filesView, "last", pages-1, "Last", page < pages-1),
+		},
+	}
+
+	return embed, []discordgo.MessageComponent{row}, true
+}
+
+// fileInfo describes entry as an embed. Embedded artwork is returned as a file to attach alongside it, or nil if
+// there isn't any.
+func fileInfo(entry libraryEntry) (*discordgo.MessageEmbed, *discordgo.File) {
+	album, duration := "-", "-"
+	if entry.album != "" {
+		album = entry.album
+	}
+	if entry.duration > 0 {
+		duration = entry.duration.Round(time.Second).String()
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: entry.displayTitle(),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Artist", Value: entry.displayArtist(), Inline: true},
+			{Name: "Album", Value: album, Inline: true},
+			{Name: "Duration", Value: duration, Inline: true},
+			{Name: "Path", Value: fmt.Sprintf("`%s`", entry.ref()), Inline: true},
+			{Name: "Size", Value: fmt.Sprintf("%.1f MB", float64(entry.size)/(1<<20)), Inline: true},
+		},
+	}
+
+	artwork, mime := entryArtwork(entry)
+	if len(artwork) == 0 {
+		return embed, nil
+	}
+
+	name := "artwork.jpg"
+	if mime == "image/png" {
+		name = "artwork.png"
+	}
+	embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: "attachment://" + name}
+
+	return embed, &discordgo.File{
+		Name:        name,
+		ContentType: mime,
+		Reader:      bytes.NewReader(artwork),
+	}
+}
+
+// entryArtwork returns the artwork embedded in entry along with its MIME type, or nil if there isn't any or it's over
+// maxArtworkSize. The file is only read if the scan found artwork in it, and the result is cached until it changes.
+func entryArtwork(entry libraryEntry) ([]byte, string) {
+	if entry.artworkMime == "" {
+		return nil, ""
+	}
+
+	path := entry.fullPath()
+	if cached, ok := artworkCache.Get(path); ok && cached.modTime.Equal(entry.modTime) && cached.size == entry.size {
+		return cached.data, cached.mime
+	}
+
+	tags, err := readTags(path)
+	if err != nil {
+		return nil, ""
+	}
+
+	artwork := cachedArtwork{modTime: entry.modTime, size: entry.size}
+	if len(tags.artwork) <= maxArtworkSize {
+		artwork.data, artwork.mime = tags.artwork, tags.artworkMime
+	}
+
+	if artworkCache.Len() >= artworkCacheSize {
+		artworkCache.Empty()
+	}
+	artworkCache.Set(path, artwork)
+
+	return artwork.data, artwork.mime
+}
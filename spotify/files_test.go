@@ -0,0 +1,164 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"song.mp3", true},
+		{"song (1).mp3", true},
+		{".hidden.mp3", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"../song.mp3", false},
+		{"/song.mp3", false},
+		{`..\song.mp3`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validFileName(test.name); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsAudio(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   bool
+	}{
+		{"id3", []byte("ID3\x04\x00"), true},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), true},
+		{"ogg", []byte("OggS\x00\x02"), true},
+		{"wavpack", []byte("wvpk"), true},
+		{"monkey's audio", []byte("MAC \x96\x0f"), true},
+		{"amr", []byte("#!AMR\n"), true},
+		{"asf", []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11, 0xA6, 0xD9}, true},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), true},
+		{"aiff", []byte("FORM\x00\x00\x00\x00AIFF"), true},
+		{"aifc", []byte("FORM\x00\x00\x00\x00AIFC"), true},
+		{"mp4", []byte("\x00\x00\x00\x20ftypM4A "), true},
+		{"bare mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x64}, true},
+		{"bare aac frame", []byte{0xFF, 0xF1, 0x50, 0x80}, true},
+		{"empty", nil, false},
+		{"avi", []byte("RIFF\x24\x00\x00\x00AVI LIST"), false},
+		{"short riff", []byte("RIFF\x24\x00"), false},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), false},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, false},
+		{"text", []byte("hello world"), false},
+		{"zip", []byte("PK\x03\x04"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isAudio(test.header); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFreeFilePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		file     string
+		want     string
+	}{
+		{"free", nil, "song.mp3", "song.mp3"},
+		{"taken", []string{"song.mp3"}, "song.mp3", "song (1).mp3"},
+		{"several taken", []string{"song.mp3", "song (1).mp3", "song (2).mp3"}, "song.mp3", "song (3).mp3"},
+		{"gap", []string{"song.mp3", "song (2).mp3"}, "song.mp3", "song (1).mp3"},
+		{"no extension", []string{"song"}, "song", "song (1)"},
+		{"several extensions", []string{"song.tar.gz"}, "song.tar.gz", "song.tar (1).gz"},
+		{"other file", []string{"other.mp3"}, "song.mp3", "song.mp3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range test.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := freeFilePath(dir, test.file)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := filepath.Join(dir, test.want); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestFreeFilePathExhausted(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"song.mp3"}
+	for n := 1; n <= maxNameAttempts; n++ {
+		names = append(names, fmt.Sprintf("song (%d).mp3", n))
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := freeFilePath(dir, "song.mp3"); !errors.Is(err, errFileExists) {
+		t.Errorf("got %v, want %v", err, errFileExists)
+	}
+}
+
+func TestFreeFilePathError(t *testing.T) {
+	// A path through a file can't be looked up, which mustn't be mistaken for the name being taken
+	dir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := freeFilePath(dir, "song.mp3"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestMegabytes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"50", 50 << 20},
+		{"1", 1 << 20},
+		{"0.5", 1 << 19},
+		{" 50", 0},
+		{"", 0},
+		{"0", 0},
+		{"-5", 0},
+		{"fifty", 0},
+		{"NaN", 0},
+		{"Inf", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			if got := megabytes(test.value); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
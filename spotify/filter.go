@@ -0,0 +1,268 @@
+package spotify
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// loudnessBlockSamples is the length of a single loudness measurement block (100ms at 48kHz).
+	loudnessBlockSamples = discordFrequency / 10
+	// loudnessBlocks is the number of blocks in the measurement window. 3s matches EBU R128 short-term loudness.
+	loudnessBlocks = 30
+	// loudnessGate is the absolute gate in LUFS. Anything quieter is treated as silence and doesn't move the gain.
+	loudnessGate = -70.0
+	// maxBoost and maxCut limit how far the normalizer is allowed to move the gain, in dB.
+	maxBoost = 6.0
+	maxCut   = -24.0
+	// gainSmoothing is the time constant, in seconds, used to ease into a new gain.
+	gainSmoothing = 0.5
+	// limiterCeiling is the highest sample value the limiter lets through, just under full scale.
+	limiterCeiling = 0.98
+	// limiterRelease is the time constant, in seconds, the limiter takes to let go after a peak.
+	limiterRelease = 0.1
+)
+
+// audioFilter applies the per-session volume and loudness normalization to decoded samples.
+type audioFilter struct {
+	mu         sync.Mutex
+	volume     float64
+	normalizer *loudnessNormalizer
+	// limiterGain is the gain reduction the limiter is currently applying.
+	limiterGain float64
+}
+
+func newAudioFilter() *audioFilter {
+	return &audioFilter{volume: 1, limiterGain: 1}
+}
+
+// SetVolume sets the output volume as a percentage. 100 leaves the samples untouched.
+func (f *audioFilter) SetVolume(percent int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.volume = math.Max(0, float64(percent)/100)
+}
+
+// Volume returns the output volume as a percentage.
+func (f *audioFilter) Volume() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return int(math.Round(f.volume * 100))
+}
+
+// SetLoudnessTarget enables loudness normalization towards target LUFS. Normalization is disabled when enabled is
+// false.
+func (f *audioFilter) SetLoudnessTarget(target float64, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !enabled {
+		f.normalizer = nil
+		return
+	}
+
+	if f.normalizer == nil {
+		f.normalizer = newLoudnessNormalizer(target)
+	} else {
+		f.normalizer.target = target
+	}
+}
+
+// reset clears any measurements carried over from a previous playable. The current gain is kept so the transition
+// between playables stays smooth.
+func (f *audioFilter) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.normalizer != nil {
+		f.normalizer.reset()
+	}
+}
+
+// process modifies samples in place.
+func (f *audioFilter) process(samples [][]float32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.normalizer != nil {
+		f.normalizer.process(samples)
+	}
+
+	if f.volume != 1 {
+		for _, sample := range samples {
+			for ch := range sample {
+				sample[ch] *= float32(f.volume)
+			}
+		}
+	}
+
+	f.limit(samples)
+}
+
+// limit keeps boosted samples from clipping when they're encoded. Peaks are pulled under limiterCeiling straight away,
+// and the gain eases back once they've passed. The caller is expected to hold f.mu.
+func (f *audioFilter) limit(samples [][]float32) {
+	release := 1 - math.Exp(-1/(limiterRelease*discordFrequency))
+
+	for _, sample := range samples {
+		peak := 0.0
+		for ch := range sample {
+			peak = math.Max(peak, math.Abs(float64(sample[ch])))
+		}
+
+		f.limiterGain = math.Min(1, f.limiterGain+(1-f.limiterGain)*release)
+		if f.limiterGain > 0.9999 {
+			f.limiterGain = 1
+		}
+		if peak*f.limiterGain > limiterCeiling {
+			f.limiterGain = limiterCeiling / peak
+		}
+
+		if f.limiterGain == 1 {
+			continue
+		}
+		for ch := range sample {
+			sample[ch] *= float32(f.limiterGain)
+		}
+	}
+}
+
+// loudnessNormalizer continuously measures short-term loudness as described in ITU-R BS.1770 (the basis of EBU R128)
+// and eases the gain towards whatever brings it to target.
+type loudnessNormalizer struct {
+	target float64
+
+	// shelf and highpass make up the K-weighting filter, one of each per channel.
+	shelf    []biquad
+	highpass []biquad
+
+	blockSum    float64
+	blockLen    int
+	blocks      [loudnessBlocks]float64
+	blockIndex  int
+	blockFilled int
+
+	gain       float64
+	targetGain float64
+}
+
+func newLoudnessNormalizer(target float64) *loudnessNormalizer {
+	return &loudnessNormalizer{
+		target:     target,
+		gain:       1,
+		targetGain: 1,
+	}
+}
+
+func (l *loudnessNormalizer) reset() {
+	l.blockSum = 0
+	l.blockLen = 0
+	l.blockIndex = 0
+	l.blockFilled = 0
+}
+
+// Loudness returns the short-term loudness in LUFS of the most recent measurement window.
+func (l *loudnessNormalizer) Loudness() float64 {
+	if l.blockFilled == 0 {
+		return math.Inf(-1)
+	}
+
+	sum := 0.0
+	for i := 0; i < l.blockFilled; i++ {
+		sum += l.blocks[i]
+	}
+
+	return -0.691 + 10*math.Log10(sum/float64(l.blockFilled))
+}
+
+func (l *loudnessNormalizer) process(samples [][]float32) {
+	if len(samples) == 0 {
+		return
+	}
+
+	if len(l.shelf) != len(samples[0]) {
+		l.shelf = make([]biquad, len(samples[0]))
+		l.highpass = make([]biquad, len(samples[0]))
+		for ch := range l.shelf {
+			l.shelf[ch] = kWeightingShelf()
+			l.highpass[ch] = kWeightingHighpass()
+		}
+	}
+
+	smoothing := 1 - math.Exp(-1/(gainSmoothing*discordFrequency))
+
+	for _, sample := range samples {
+		for ch := range sample {
+			k := l.highpass[ch].process(l.shelf[ch].process(float64(sample[ch])))
+			l.blockSum += k * k
+		}
+
+		l.blockLen++
+		if l.blockLen == loudnessBlockSamples {
+			l.pushBlock()
+		}
+
+		l.gain += (l.targetGain - l.gain) * smoothing
+		for ch := range sample {
+			sample[ch] *= float32(l.gain)
+		}
+	}
+}
+
+// pushBlock finishes the current measurement block and recalculates the target gain.
+func (l *loudnessNormalizer) pushBlock() {
+	l.blocks[l.blockIndex] = l.blockSum / float64(l.blockLen)
+	l.blockIndex = (l.blockIndex + 1) % loudnessBlocks
+	if l.blockFilled < loudnessBlocks {
+		l.blockFilled++
+	}
+	l.blockSum = 0
+	l.blockLen = 0
+
+	loudness := l.Loudness()
+	if loudness < loudnessGate {
+		return
+	}
+
+	db := math.Max(maxCut, math.Min(maxBoost, l.target-loudness))
+	l.targetGain = math.Pow(10, db/20)
+}
+
+// biquad is a second order IIR filter in transposed direct form II.
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+func (b *biquad) process(x float64) float64 {
+	y := b.b0*x + b.z1
+	b.z1 = b.b1*x - b.a1*y + b.z2
+	b.z2 = b.b2*x - b.a2*y
+
+	return y
+}
+
+// kWeightingShelf returns the first stage of the BS.1770 K-weighting filter (a high shelf) for 48kHz.
+func kWeightingShelf() biquad {
+	return biquad{
+		b0: 1.53512485958697,
+		b1: -2.69169618940638,
+		b2: 1.19839281085285,
+		a1: -1.69065929318241,
+		a2: 0.73248077421585,
+	}
+}
+
+// kWeightingHighpass returns the second stage of the BS.1770 K-weighting filter (a high pass) for 48kHz.
+func kWeightingHighpass() biquad {
+	return biquad{
+		b0: 1.0,
+		b1: -2.0,
+		b2: 1.0,
+		a1: -1.99004745483398,
+		a2: 0.99007225036621,
+	}
+}
@@ -0,0 +1,143 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/olympus-go/apollo"
+	"github.com/olympus-go/apollo/spotify"
+)
+
+const playlistsFilename = "playlists.json"
+
+// localFilePrefix marks an id passed to enqueueTrackIds as a local file rather than a spotify track id.
+const localFilePrefix = "file:"
+
+var (
+	errPlaylistNotFound = errors.New("playlist not found")
+	errPlaylistExists   = errors.New("playlist already exists")
+	errPlaylistDenied   = errors.New("not allowed to change playlist")
+	errPlaylistPosition = errors.New("position not in playlist")
+)
+
+// playlistsMu guards reading and writing every guild's saved playlists.
+var playlistsMu sync.Mutex
+
+// savedPlaylist is a named list of tracks saved by a guild, independent of any spotify account.
+type savedPlaylist struct {
+	Name        string          `json:"name"`
+	CreatorId   string          `json:"creator_id"`
+	CreatorName string          `json:"creator_name"`
+	Tracks      []playlistEntry `json:"tracks"`
+}
+
+// playlistEntry is a single track in a savedPlaylist. Exactly one of TrackId or File is set. Name and Artist are only
+// kept so the playlist can be shown without looking every track up again.
+type playlistEntry struct {
+	TrackId string `json:"track_id,omitempty"`
+	File    string `json:"file,omitempty"`
+	Name    string `json:"name"`
+	Artist  string `json:"artist"`
+}
+
+// queueId returns the id enqueueTrackIds expects for e.
+func (e playlistEntry) queueId() string {
+	if e.File != "" {
+		return localFilePrefix + e.File
+	}
+
+	return e.TrackId
+}
+
+// playlistEntryFor returns the playlist entry for playable. ok is false if it can't be saved.
+func playlistEntryFor(playable apollo.Playable) (playlistEntry, bool) {
+	switch t := playable.(type) {
+	case *track:
+		return playlistEntry{TrackId: t.Id(), Name: t.Name(), Artist: t.Artist()}, true
+	case spotify.Track:
+		return playlistEntry{TrackId: t.Id(), Name: t.Name(), Artist: t.Artist()}, true
+	case *localFile:
+		return playlistEntry{File: t.entry.ref(), Name: t.Name(), Artist: t.Artist()}, true
+	}
+
+	return playlistEntry{}, false
+}
+
+// playlistKey returns the key a playlist is stored under, which makes names case-insensitive.
+func playlistKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// guildDir returns the directory that per-guild state is kept in.
+func guildDir(guildId string) string {
+	return filepath.Join(spotify.DefaultSessionConfig().ConfigHomeDir, guildId)
+}
+
+// loadPlaylists reads the guild's saved playlists from disk. A guild without any returns an empty map.
+func loadPlaylists(guildId string) (map[string]savedPlaylist, error) {
+	playlists := make(map[string]savedPlaylist)
+
+	b, err := os.ReadFile(filepath.Join(guildDir(guildId), playlistsFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return playlists, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &playlists)
+
+	return playlists, err
+}
+
+// updatePlaylists loads the guild's saved playlists, applies update to them, and writes them back to disk. Nothing is
+// written if update returns an error.
+func updatePlaylists(guildId string, update func(map[string]savedPlaylist) error) error {
+	playlistsMu.Lock()
+	defer playlistsMu.Unlock()
+
+	playlists, err := loadPlaylists(guildId)
+	if err != nil {
+		return err
+	}
+
+	if err = update(playlists); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(playlists)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(guildDir(guildId), playlistsFilename)
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// Same as snapshots, write to a temporary file first so a crash mid-write doesn't lose every playlist.
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, b, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// sortedPlaylists returns the guild's saved playlists ordered by name.
+func sortedPlaylists(playlists map[string]savedPlaylist) []savedPlaylist {
+	sorted := make([]savedPlaylist, 0, len(playlists))
+	for _, playlist := range playlists {
+		sorted = append(sorted, playlist)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return playlistKey(sorted[i].Name) < playlistKey(sorted[j].Name)
+	})
+
+	return sorted
+}
@@ -1,15 +1,14 @@
 package spotify
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"math/rand"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +23,8 @@ import (
 
 const playQueryStr = "%s\n```Name: %s\nArtist: %s\n```%s"
 const playlistQueryStr = "Play all of the `%s` playlist?\n%s"
+const albumQueryStr = "Play all of the `%s` album?\n%s"
+const artistQueryStr = "Play the top tracks of `%s`?\n%s"
 
 func (p *Plugin) spotifyHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	switch i.Type {
@@ -70,7 +71,29 @@ func (p *Plugin) spotifyHandler(discordSession *discordgo.Session, i *discordgo.
 			p.clearHandler(discordSession, i)
 		case "shuffle":
 			p.shuffleHandler(discordSession, i)
+		case "loop":
+			p.loopHandler(discordSession, i)
+		case "seek":
+			p.seekHandler(discordSession, i)
+		case "volume":
+			p.volumeHandler(discordSession, i)
+		case "move":
+			p.moveHandler(discordSession, i)
+		case "ban", "unban":
+			p.banHandler(discordSession, i)
+		case "autoplay":
+			p.autoplayHandler(discordSession, i)
+		case "playlist":
+			p.guildPlaylistHandler(discordSession, i)
+		case "files":
+			p.filesHandler(discordSession, i)
+		case "export":
+			p.exportHandler(discordSession, i)
+		case "import":
+			p.importHandler(discordSession, i)
 		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		p.autocompleteHandler(discordSession, i)
 	case discordgo.InteractionMessageComponent:
 		switch {
 		case utils.IsInteractionMessageComponent(i, "startsWith", "spotify_playlist"):
@@ -81,6 +104,15 @@ func (p *Plugin) spotifyHandler(discordSession *discordgo.Session, i *discordgo.
 			p.loginMessageHandler(discordSession, i)
 		case utils.IsInteractionMessageComponent(i, "startsWith", "spotify_quiz"):
 			p.quizMessageHandler(discordSession, i)
+		case utils.IsInteractionMessageComponent(i, "startsWith", "spotify_vote"):
+			p.voteMessageHandler(discordSession, i)
+		case utils.IsInteractionMessageComponent(i, "startsWith", "spotify_page"):
+			p.pageMessageHandler(discordSession, i)
+		case utils.IsInteractionMessageComponent(i, "startsWith", "spotify_panel"):
+			p.panelMessageHandler(discordSession, i)
+		case utils.IsInteractionMessageComponent(i, "startsWith", "spotify_ban"),
+			utils.IsInteractionMessageComponent(i, "startsWith", "spotify_unban"):
+			p.banMessageHandler(discordSession, i)
 		}
 	}
 }
@@ -101,11 +133,12 @@ func (p *Plugin) joinHandler(discordSession *discordgo.Session, i *discordgo.Int
 	if !ok {
 		logger.Debug("creating new spotify session for guild", slog.String("guild_id", i.Interaction.GuildID))
 
-		sessionConfig := spotify.DefaultSessionConfig()
-		sessionConfig.ConfigHomeDir = filepath.Join(sessionConfig.ConfigHomeDir, i.Interaction.GuildID)
-		sessionConfig.OAuthCallback = p.config.SpotifyCallbackUrl
-		spotSession = newSession(i.Interaction.GuildID, sessionConfig, p.logger.Handler(), p.config.AdminIds...)
-		p.sessions.Set(i.Interaction.GuildID, spotSession)
+		spotSession = p.createSession(i.Interaction.GuildID)
+	}
+
+	// Retry the restore in case it failed when the session was created
+	if err := p.restoreSession(spotSession); err != nil {
+		logger.Error("failed to restore queue snapshot", slog.String("error", err.Error()))
 	}
 
 	err := spotSession.joinVoice(discordSession, i.Interaction)
@@ -132,6 +165,10 @@ func (p *Plugin) joinHandler(discordSession *discordgo.Session, i *discordgo.Int
 		return
 	}
 
+	if strings.ToLower(p.config.NowPlayingPanel) == "true" {
+		spotSession.startPanel(discordSession, i.Interaction.ChannelID)
+	}
+
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
 		Message(p.config.JoinCommand.Responses.JoinSuccess).
@@ -255,20 +292,73 @@ func (p *Plugin) playHandler(discordSession *discordgo.Session, i *discordgo.Int
 	}
 
 	frequency := discordFrequency
-	//remixOption := utils.GetCommandOption(playOption, "play", "remix")
-	//if remixOption != nil {
-	//	frequency = int(remixOption.IntValue())
-	//}
+	remixOption := utils.GetCommandOption(*playOption, "play", "remix")
+	if remixOption != nil {
+		frequency = int(remixOption.IntValue())
+	}
+
+	// Generate an uid for tracking future interactions
+	uid := utils.ShaSum(fmt.Sprintf("%s%s%d",
+		i.Interaction.GuildID,
+		utils.GetInteractionUserId(i.Interaction),
+		time.Now().UnixNano(),
+	))
 
 	// Check if the query is a local file. If it exists, queue that, otherwise continue.
 	userId := utils.GetInteractionUserId(i.Interaction)
 	username := utils.GetInteractionUserName(i.Interaction)
-	if localFile, err := p.getLocalFile(query, userId, username); err == nil {
+	if localFile, err := p.getLocalFile(i.GuildID, query, userId, username); err == nil {
+		if p.isBanned(i.GuildID, &localFile) {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.PlayCommand.Responses.BannedTrack).
+				EditWithLog(logger)
+			return
+		}
+
+		if message := p.checkQueueRoom(spotSession, userId); message != "" {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(message).
+				EditWithLog(logger)
+			return
+		}
+
+		if message := p.checkTrackDuration(&localFile, userId); message != "" {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(message).
+				EditWithLog(logger)
+			return
+		}
+
+		localFile.Mdata["frequency"] = strconv.Itoa(frequency)
+
+		if spotSession.isQueued(&localFile) {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.PlayCommand.Responses.DuplicatePrompt).
+				Components(duplicateButtons(uid)...).
+				EditWithLog(logger)
+
+			spotSession.playInteractions.Set(uid, playInteraction{
+				position:  position,
+				frequency: frequency,
+				localFile: &localFile,
+			})
+			logger.Debug("play interaction created", slog.String("uid", uid))
+			spotSession.expirePlayInteraction(discordSession, i.Interaction, uid, logger)
+
+			return
+		}
+
 		if position != -1 {
 			spotSession.player.Insert(spotSession.player.Cursor()+position-1, &localFile)
+			spotSession.pin(&localFile)
 		} else {
 			spotSession.player.Enqueue(&localFile)
 		}
+		p.rebalanceQueue(spotSession)
 		if spotSession.player.State() == apollo.IdleState {
 			spotSession.player.Play()
 		}
@@ -282,19 +372,18 @@ func (p *Plugin) playHandler(discordSession *discordgo.Session, i *discordgo.Int
 		return
 	}
 
-	// Generate an uid for tracking future interactions
-	uid := utils.ShaSum(fmt.Sprintf("%s%s%d",
-		i.Interaction.GuildID,
-		utils.GetInteractionUserId(i.Interaction),
-		time.Now().UnixNano(),
-	))
-
-	// Check if the query is a link to a playlist. If it is, we'll send a special message for queueing the entire thing.
+	// Check if the query is a link to a playlist, album or artist. If it is, we'll send a special message for queueing
+	// the entire thing.
 	uri, ok := spotify.ConvertLinkToUri(query)
-	if ok && uri.Authority == spotify.PlaylistResourceType {
-		playlists, err := spotSession.session.Search(query).Limit(1).Playlists()
-		if err != nil || len(playlists) == 0 {
-			logger.Error("playlist search failed", slog.String("error", err.Error()))
+	if ok && (uri.Authority == spotify.PlaylistResourceType ||
+		uri.Authority == spotify.AlbumResourceType ||
+		uri.Authority == spotify.ArtistResourceType) {
+		c, err := getCollection(spotSession.session, p.web, uri, query)
+		if err != nil {
+			logger.Error("collection lookup failed",
+				slog.String("error", err.Error()),
+				slog.String("type", uri.Authority.String()),
+			)
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
 				Message(p.config.GlobalResponses.GenericError).
@@ -302,52 +391,113 @@ func (p *Plugin) playHandler(discordSession *discordgo.Session, i *discordgo.Int
 			return
 		}
 
-		trackIds := playlists[0].TrackIds()
-
-		message := fmt.Sprintf(playlistQueryStr, playlists[0].Name(), playlists[0].Image())
-		yesButton := utils.Button().Label("Yes").Id("spotify_playlist_yes_" + uid).Build()
-		noButton := utils.Button().Style(discordgo.SecondaryButton).Label("No").Id("spotify_playlist_no_" + uid).Build()
-		shuffleButton := utils.Button().Label("Shuffle").Id("spotify_playlist_shuffle_" + uid).Build()
+		if len(c.trackIds) == 0 {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.PlayCommand.Responses.NoTracksFound).
+				EditWithLog(logger)
+			return
+		}
 
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Components(utils.ActionsRow().Button(yesButton).Button(noButton).Button(shuffleButton).Build()).
-			Message(message).
+			Components(collectionButtons(uid)).
+			Message(c.prompt()).
 			EditWithLog(logger)
 
 		spotSession.playInteractions.Set(uid, playInteraction{
-			trackIds:     trackIds,
-			playlistName: playlists[0].Name(),
-			frequency:    frequency,
+			trackIds:   c.trackIds,
+			collection: &c,
+			frequency:  frequency,
+		})
+		logger.Debug("play interaction created", slog.String("uid", uid))
+
+		return
+	}
+
+	// Track links are unambiguous (and are what autocomplete suggests), so there's no need to ask which one they meant
+	if ok && uri.Authority == spotify.TrackResourceType {
+		spotSession.playInteractions.Set(uid, playInteraction{
+			trackIds:  []string{uri.Path},
+			position:  position,
+			frequency: frequency,
 		})
 		logger.Debug("play interaction created", slog.String("uid", uid))
 
+		p.enqueueInteraction(discordSession, i, spotSession, uid, false, logger)
+		spotSession.expirePlayInteraction(discordSession, i.Interaction, uid, logger)
+
 		return
 	}
 
+	interaction := playInteraction{
+		position:  position,
+		frequency: frequency,
+	}
+
+	// Local files are offered first, since anyone searching for one by its title or artist almost certainly wants it
+	for _, entry := range p.searchLocalFiles(i.GuildID, query) {
+		if len(interaction.trackIds) == librarySearchLimit {
+			break
+		}
+
+		// Select menu values can't be truncated without losing track of the file
+		if len(localFilePrefix+entry.ref()) > maxChoiceLength {
+			continue
+		}
+
+		interaction.trackIds = append(interaction.trackIds, localFilePrefix+entry.ref())
+		interaction.results = append(interaction.results, entry.resultOption())
+	}
+
 	trackIds, err := spotSession.session.Search(query).Limit(queryLimit).TrackIds()
 	if err != nil {
 		logger.Error("spotify search failed", slog.String("error", err.Error()))
+		if len(interaction.trackIds) == 0 {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.GenericError).
+				EditWithLog(logger)
+			return
+		}
+	}
+
+	if len(trackIds) == 0 && len(interaction.trackIds) == 0 {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.GlobalResponses.GenericError).
+			Message(p.config.PlayCommand.Responses.NoTracksFound).
 			EditWithLog(logger)
 		return
 	}
 
-	if len(trackIds) == 0 {
+	// Every result is looked up now so they can all be listed in the select menu
+	for _, trackId := range trackIds {
+		t, err := spotSession.session.GetTrackById(trackId)
+		if err != nil {
+			logger.Error("failed to retrieve track by id",
+				slog.String("error", err.Error()),
+				slog.String("id", trackId),
+			)
+			continue
+		}
+
+		interaction.trackIds = append(interaction.trackIds, t.Id())
+		interaction.results = append(interaction.results, resultOption(t))
+	}
+
+	if len(interaction.trackIds) == 0 {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.PlayCommand.Responses.NoTracksFound).
+			Message(p.config.GlobalResponses.GenericError).
 			EditWithLog(logger)
 		return
 	}
 
-	initialTrack, err := spotSession.session.GetTrackById(trackIds[0])
+	message, err := p.resultPrompt(spotSession, interaction.trackIds[0])
 	if err != nil {
-		logger.Error("failed to retrieve track by id",
+		logger.Error("failed to retrieve result",
 			slog.String("error", err.Error()),
-			slog.String("id", trackIds[0]),
+			slog.String("id", interaction.trackIds[0]),
 		)
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
@@ -356,30 +506,15 @@ func (p *Plugin) playHandler(discordSession *discordgo.Session, i *discordgo.Int
 		return
 	}
 
-	message := fmt.Sprintf(playQueryStr,
-		p.config.PlayCommand.Responses.SongPrompt, initialTrack.Name(), initialTrack.Artist(), initialTrack.Image())
-
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
 		Message(message).
-		Components(yesNoButtons(uid, true)...).
+		Components(searchComponents(uid, interaction)...).
 		EditWithLog(logger)
 
-	spotSession.playInteractions.Set(uid, playInteraction{
-		trackIds:  trackIds,
-		position:  position,
-		frequency: frequency,
-	})
+	spotSession.playInteractions.Set(uid, interaction)
 	logger.Debug("play interaction created", slog.String("uid", uid))
-
-	go func() {
-		time.Sleep(60 * time.Second)
-		if _, ok = spotSession.playInteractions.Get(uid); ok {
-			utils.InteractionResponse(discordSession, i.Interaction).DeleteWithLog(logger)
-			spotSession.playInteractions.Delete(uid)
-			logger.Debug("play interaction timed out", slog.String("uid", uid))
-		}
-	}()
+	spotSession.expirePlayInteraction(discordSession, i.Interaction, uid, logger)
 }
 
 func (p *Plugin) playMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -437,178 +572,418 @@ func (p *Plugin) playMessageHandler(discordSession *discordgo.Session, i *discor
 	}
 
 	switch action {
-	case "yes":
+	case "yes", "anyway":
+		p.enqueueInteraction(discordSession, i, spotSession, uid, action == "anyway", logger)
+	case "cancel":
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.PlayCommand.Responses.DuplicateCancelled).
+			Components().
+			EditWithLog(logger)
+
+		spotSession.playInteractions.Delete(uid)
+	case "no":
 		interaction, ok := spotSession.playInteractions.Get(uid)
 		if !ok || len(interaction.trackIds) == 0 {
 			logger.Error("tracks no longer exist for uid", slog.String("uid", uid))
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
 				Message(p.config.GlobalResponses.GenericError).
-				FollowUpCreateWithLog(logger)
+				EditWithLog(logger)
+			return
+		}
+
+		logger.Debug("user responded no to track", slog.Any("track", interaction.trackIds[0]))
+
+		interaction.trackIds = interaction.trackIds[1:]
+		spotSession.playInteractions.Set(uid, interaction)
+
+		if len(interaction.trackIds) == 0 {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.PlayCommand.Responses.EndOfList).
+				EditWithLog(logger)
+			spotSession.playInteractions.Delete(uid)
 			return
 		}
 
-		spotTrack, err := spotSession.session.GetTrackById(interaction.trackIds[0])
+		message, err := p.resultPrompt(spotSession, interaction.trackIds[0])
 		if err != nil {
-			logger.Error("failed to get track by id",
+			logger.Error("failed to retrieve result",
 				slog.String("error", err.Error()),
 				slog.String("trackId", interaction.trackIds[0]),
 			)
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
 				Message(p.config.GlobalResponses.GenericError).
-				FollowUpCreateWithLog(logger)
+				EditWithLog(logger)
 
 			spotSession.playInteractions.Delete(uid)
 
 			return
 		}
 
-		if slices.Contains(p.config.BannedTracks, spotTrack.Id()) {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(message).
+			Components(searchComponents(uid, interaction)...).
+			EditWithLog(logger)
+	case "multi":
+		// The interaction may have been finished or pruned since the check above
+		interaction, ok := spotSession.playInteractions.Get(uid)
+		if !ok {
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
-				Message(p.config.PlayCommand.Responses.BannedTrack).
+				Message(p.config.PlayCommand.Responses.ListNotAvailable).
+				Components().
 				EditWithLog(logger)
-
-			spotSession.playInteractions.Delete(uid)
 			return
 		}
+		interaction.multi = !interaction.multi
+		spotSession.playInteractions.Set(uid, interaction)
 
-		t := &track{
-			Track: spotTrack,
-			metadata: map[string]string{
-				"requesterId":   utils.GetInteractionUserId(i.Interaction),
-				"requesterName": utils.GetInteractionUserName(i.Interaction),
-				"frequency":     fmt.Sprintf("%d", discordFrequency),
-			},
-		}
-
-		if interaction.position != -1 {
-			spotSession.player.Insert(spotSession.player.Cursor()+interaction.position-1, t)
-		} else {
-			spotSession.player.Enqueue(t)
-		}
-
-		message := fmt.Sprintf("%s by %s added to queue.", t.Name(), t.Artist())
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(message).
+			Message(i.Message.Content).
+			Components(searchComponents(uid, interaction)...).
 			EditWithLog(logger)
-
-		spotSession.playInteractions.Delete(uid)
-
-		if spotSession.player.State() == apollo.IdleState {
-			spotSession.player.Play()
-		}
-	case "no":
+	case "select":
 		interaction, ok := spotSession.playInteractions.Get(uid)
-		if !ok || len(interaction.trackIds) == 0 {
-			logger.Error("tracks no longer exist for uid", slog.String("uid", uid))
+		if !ok {
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
-				Message(p.config.GlobalResponses.GenericError).
+				Message(p.config.PlayCommand.Responses.ListNotAvailable).
+				Components().
 				EditWithLog(logger)
 			return
 		}
+		trackIds := messageData.Values
+		if len(trackIds) == 0 {
+			return
+		}
 
-		logger.Debug("user responded no to track", slog.Any("track", interaction.trackIds[0]))
-
-		interaction.trackIds = interaction.trackIds[1:]
-		spotSession.playInteractions.Set(uid, interaction)
+		logger.Debug("user selected tracks", slog.Any("tracks", trackIds))
 
-		if len(interaction.trackIds) == 0 {
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.PlayCommand.Responses.EndOfList).
-				EditWithLog(logger)
-			spotSession.playInteractions.Delete(uid)
+		// A single pick goes through the same path as "yes" so that it still gets checked for duplicates
+		if len(trackIds) == 1 {
+			interaction.trackIds = trackIds
+			spotSession.playInteractions.Set(uid, interaction)
+			p.enqueueInteraction(discordSession, i, spotSession, uid, false, logger)
 			return
 		}
 
-		t, err := spotSession.session.GetTrackById(interaction.trackIds[0])
+		queued, note := p.enqueueTrackIds(spotSession, i, trackIds, interaction.position, interaction.frequency, logger)
+		spotSession.playInteractions.Delete(uid)
+
+		message := fmt.Sprintf("%d songs added to queue.", queued)
+		if note != "" {
+			message += "\n" + note
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(message).
+			Components().
+			EditWithLog(logger)
+	}
+}
+
+// enqueueInteraction queues the track the play interaction for uid is currently on, then finishes the interaction. If
+// the track is already queued the user is asked to confirm first, unless allowDuplicate is set. i should already have
+// been responded to.
+func (p *Plugin) enqueueInteraction(discordSession *discordgo.Session, i *discordgo.InteractionCreate, spotSession *session, uid string, allowDuplicate bool, logger *slog.Logger) {
+	interaction, ok := spotSession.playInteractions.Get(uid)
+	if !ok || (len(interaction.trackIds) == 0 && interaction.localFile == nil) {
+		logger.Error("tracks no longer exist for uid", slog.String("uid", uid))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			FollowUpCreateWithLog(logger)
+		return
+	}
+
+	var t apollo.Playable
+	if interaction.localFile != nil {
+		t = interaction.localFile
+	} else {
+		var err error
+		t, err = p.resolveTrackId(spotSession, i, interaction.trackIds[0], interaction.frequency)
 		if err != nil {
-			logger.Error("failed to get track by id",
+			logger.Error("failed to resolve track",
 				slog.String("error", err.Error()),
 				slog.String("trackId", interaction.trackIds[0]),
 			)
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
 				Message(p.config.GlobalResponses.GenericError).
-				EditWithLog(logger)
+				FollowUpCreateWithLog(logger)
 
 			spotSession.playInteractions.Delete(uid)
 
 			return
 		}
 
-		message := fmt.Sprintf(playQueryStr, p.config.PlayCommand.Responses.SongPrompt, t.Name(), t.Artist(), t.Image())
+		if p.isBanned(i.GuildID, t) {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.PlayCommand.Responses.BannedTrack).
+				Components().
+				EditWithLog(logger)
+
+			spotSession.playInteractions.Delete(uid)
+			return
+		}
+	}
 
+	userId := utils.GetInteractionUserId(i.Interaction)
+	message := p.checkQueueRoom(spotSession, userId)
+	if message == "" {
+		message = p.checkTrackDuration(t, userId)
+	}
+	if message != "" {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
 			Message(message).
-			Components(yesNoButtons(uid, true)...).
+			Components().
 			EditWithLog(logger)
-	}
-}
-
-func (p *Plugin) playlistMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
-	logger := p.logger.With(
-		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
-		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
-	)
 
-	// If the session for the guild doesn't already exist, create it
-	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
-	if !ok {
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.GlobalResponses.NotInVoice).
-			SendWithLog(logger)
+		spotSession.playInteractions.Delete(uid)
 		return
 	}
 
-	if !spotSession.session.LoggedIn() {
+	// The interaction is kept around so that the user can still decide to add it anyway
+	if !allowDuplicate && spotSession.isQueued(t) {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.GlobalResponses.NotLoggedIn).
-			SendWithLog(logger)
+			Message(fmt.Sprintf("%s\n```Name: %s\nArtist: %s\n```",
+				p.config.PlayCommand.Responses.DuplicatePrompt, t.Name(), t.Artist())).
+			Components(duplicateButtons(uid)...).
+			EditWithLog(logger)
 		return
 	}
 
+	if interaction.position != -1 {
+		spotSession.player.Insert(spotSession.player.Cursor()+interaction.position-1, t)
+		spotSession.pin(t)
+	} else {
+		spotSession.player.Enqueue(t)
+	}
+	p.rebalanceQueue(spotSession)
+
+	message = fmt.Sprintf("%s by %s added to queue.", t.Name(), t.Artist())
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
-		DeferredUpdate().
-		SendWithLog(logger)
+		Message(message).
+		Components().
+		EditWithLog(logger)
 
-	messageData := i.MessageComponentData()
-	idSplit := strings.Split(messageData.CustomID, "_")
-	if len(idSplit) != 4 {
-		logger.Error("message component data interaction response had an unknown custom ID",
-			slog.String("custom_id", messageData.CustomID),
-		)
+	spotSession.playInteractions.Delete(uid)
 
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.GlobalResponses.GenericError).
-			EditWithLog(logger)
-		return
+	if spotSession.player.State() == apollo.IdleState {
+		spotSession.player.Play()
 	}
+}
 
-	action := idSplit[2]
-	uid := idSplit[3]
+// resultPrompt returns the message asking whether the search result trackId is the right one. trackId may also be a
+// local file, see resolveTrackId.
+func (p *Plugin) resultPrompt(spotSession *session, trackId string) (string, error) {
+	if path, ok := strings.CutPrefix(trackId, localFilePrefix); ok {
+		entry, ok := p.findLocalFile(spotSession.guildId, path)
+		if !ok {
+			return "", fmt.Errorf("no local file found")
+		}
 
-	if _, ok = spotSession.playInteractions.Get(uid); !ok {
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.PlayCommand.Responses.ListNotAvailable).
-			EditWithLog(logger)
-		return
+		return fmt.Sprintf(playQueryStr,
+			p.config.PlayCommand.Responses.SongPrompt, entry.displayTitle(), entry.displayArtist(), ""), nil
 	}
 
-	switch action {
-	case "yes", "shuffle":
-		interaction, ok := spotSession.playInteractions.Get(uid)
-		if !ok || len(interaction.trackIds) == 0 {
+	t, err := spotSession.session.GetTrackById(trackId)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(playQueryStr, p.config.PlayCommand.Responses.SongPrompt, t.Name(), t.Artist(), t.Image()), nil
+}
+
+// enqueueTrackIds queues every track in trackIds for the user behind i, where ids starting with localFilePrefix are
+// local file names. Tracks are only looked up as they're needed, so nothing is fetched once the queue is full. See
+// enqueuePlayables for how tracks are left out.
+func (p *Plugin) enqueueTrackIds(spotSession *session, i *discordgo.InteractionCreate, trackIds []string, position int, frequency int, logger *slog.Logger) (int, string) {
+	return p.enqueuePlayables(spotSession, i, len(trackIds), func(index int) (apollo.Playable, bool) {
+		if p.isBannedTrack(i.GuildID, trackIds[index]) {
+			return nil, false
+		}
+
+		t, err := p.resolveTrackId(spotSession, i, trackIds[index], frequency)
+		if err != nil {
+			logger.Error("failed to resolve track",
+				slog.String("error", err.Error()),
+				slog.String("trackId", trackIds[index]),
+			)
+			return nil, false
+		}
+
+		return t, true
+	}, position, logger)
+}
+
+// resolveTrackId looks up the spotify track or local file (if trackId starts with localFilePrefix) that trackId refers
+// to, requested by the user behind i.
+func (p *Plugin) resolveTrackId(spotSession *session, i *discordgo.InteractionCreate, trackId string, frequency int) (apollo.Playable, error) {
+	userId := utils.GetInteractionUserId(i.Interaction)
+	username := utils.GetInteractionUserName(i.Interaction)
+
+	if name, ok := strings.CutPrefix(trackId, localFilePrefix); ok {
+		file, err := p.getLocalFile(i.GuildID, name, userId, username)
+		if err != nil {
+			return nil, err
+		}
+		file.Mdata["frequency"] = fmt.Sprintf("%d", frequency)
+
+		return &file, nil
+	}
+
+	spotTrack, err := spotSession.session.GetTrackById(trackId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &track{
+		Track: spotTrack,
+		metadata: map[string]string{
+			"requesterId":   userId,
+			"requesterName": username,
+			"frequency":     fmt.Sprintf("%d", frequency),
+		},
+	}, nil
+}
+
+// enqueuePlayables queues count tracks for the user behind i, fetching each one from get as it goes. get returns false
+// for anything that should be passed over. Anything that's banned or over the queue limits is left out, and tracks that
+// are already queued are handled according to the playlist duplicate policy. Tracks are inserted starting at position
+// (1 = next up), or appended if it's -1. The number of tracks queued is returned along with a note explaining what was
+// left out, which is "" if nothing was.
+func (p *Plugin) enqueuePlayables(spotSession *session, i *discordgo.InteractionCreate, count int, get func(int) (apollo.Playable, bool), position int, logger *slog.Logger) (int, string) {
+	userId := utils.GetInteractionUserId(i.Interaction)
+	policy := p.playlistDuplicatePolicy()
+	limited := false
+	duplicates := false
+	queued := 0
+	for index := 0; index < count; index++ {
+		// Once the queue is out of room there's no point looking at the rest
+		if p.checkQueueRoom(spotSession, userId) != "" {
+			limited = true
+			break
+		}
+
+		t, ok := get(index)
+		if !ok || p.isBanned(i.GuildID, t) {
+			continue
+		}
+
+		if p.checkTrackDuration(t, userId) != "" {
+			limited = true
+			continue
+		}
+
+		if policy != duplicatesAllow && spotSession.isQueued(t) {
+			duplicates = true
+			if policy == duplicatesSkip {
+				continue
+			}
+		}
+
+		if position != -1 {
+			spotSession.player.Insert(spotSession.player.Cursor()+position-1+queued, t)
+			spotSession.pin(t)
+		} else {
+			spotSession.player.Enqueue(t)
+		}
+		queued++
+	}
+	p.rebalanceQueue(spotSession)
+
+	if spotSession.player.State() == apollo.IdleState {
+		spotSession.player.Play()
+	}
+
+	logger.Debug("user enqueued tracks",
+		slog.Int("queued", queued),
+		slog.Bool("limited", limited),
+		slog.Bool("duplicates", duplicates),
+	)
+
+	var notes []string
+	if limited {
+		notes = append(notes, p.config.PlayCommand.Responses.PlaylistLimited)
+	}
+	if duplicates && policy == duplicatesSkip {
+		notes = append(notes, p.config.PlayCommand.Responses.PlaylistSkipped)
+	} else if duplicates {
+		notes = append(notes, p.config.PlayCommand.Responses.PlaylistDuplicates)
+	}
+
+	return queued, strings.Join(notes, "\n")
+}
+
+func (p *Plugin) playlistMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	// If the session for the guild doesn't already exist, create it
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	if !spotSession.session.LoggedIn() {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotLoggedIn).
+			SendWithLog(logger)
+		return
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		DeferredUpdate().
+		SendWithLog(logger)
+
+	messageData := i.MessageComponentData()
+	idSplit := strings.Split(messageData.CustomID, "_")
+	if len(idSplit) != 4 {
+		logger.Error("message component data interaction response had an unknown custom ID",
+			slog.String("custom_id", messageData.CustomID),
+		)
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			EditWithLog(logger)
+		return
+	}
+
+	action := idSplit[2]
+	uid := idSplit[3]
+
+	if _, ok = spotSession.playInteractions.Get(uid); !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.PlayCommand.Responses.ListNotAvailable).
+			EditWithLog(logger)
+		return
+	}
+
+	switch action {
+	case "yes", "shuffle":
+		interaction, ok := spotSession.playInteractions.Get(uid)
+		if !ok || len(interaction.trackIds) == 0 {
 			logger.Error("tracks no longer exist for uid", slog.String("uid", uid))
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
@@ -630,43 +1005,20 @@ func (p *Plugin) playlistMessageHandler(discordSession *discordgo.Session, i *di
 			})
 		}
 
-		for _, trackId := range trackIds {
-			if slices.Contains(p.config.BannedTracks, trackId) {
-				continue
-			}
-
-			spotTrack, err := spotSession.session.GetTrackById(trackId)
-			if err != nil {
-				logger.Error("failed to get track by id",
-					slog.String("error", err.Error()),
-					slog.String("trackId", interaction.trackIds[0]),
-				)
-				continue
-			}
-
-			t := &track{
-				Track: spotTrack,
-				metadata: map[string]string{
-					"requesterId":   utils.GetInteractionUserId(i.Interaction),
-					"requesterName": utils.GetInteractionUserName(i.Interaction),
-					"frequency":     fmt.Sprintf("%d", discordFrequency),
-				},
-			}
-
-			spotSession.player.Enqueue(t)
-		}
-
+		_, note := p.enqueueTrackIds(spotSession, i, trackIds, -1, interaction.frequency, logger)
 		spotSession.playInteractions.Delete(uid)
 
-		if spotSession.player.State() == apollo.IdleState {
-			spotSession.player.Play()
+		message := p.config.GlobalResponses.GenericSuccess
+		if interaction.collection != nil {
+			message = interaction.collection.queued()
+		}
+		if note != "" {
+			message += "\n" + note
 		}
-
-		logger.Debug("user enqueued playlist")
 
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(fmt.Sprintf("Playlist `%s` added to queue.", interaction.playlistName)).
+			Message(message).
 			EditWithLog(logger)
 	case "no":
 		utils.InteractionResponse(discordSession, i.Interaction).
@@ -693,57 +1045,99 @@ func (p *Plugin) queueHandler(discordSession *discordgo.Session, i *discordgo.In
 		return
 	}
 
+	embed, components, ok := spotSession.renderPage(queueView, 0)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.QueueCommand.Responses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
-		Deferred().
+		Embeds(embed).
+		Components(components...).
 		SendWithLog(logger)
+}
 
-	np, ok := spotSession.player.NowPlaying()
-	if !ok {
+func (p *Plugin) pageMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	idSplit := strings.Split(i.MessageComponentData().CustomID, "_")
+	if len(idSplit) != 5 {
+		logger.Error("message component data interaction response had an unknown custom ID",
+			slog.String("custom_id", i.MessageComponentData().CustomID),
+		)
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.QueueCommand.Responses.EmptyQueue).
-			EditWithLog(logger)
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
 		return
 	}
 
-	remix := ""
-	//timeRatio := 1.0
-	elapsedDuration := time.Duration(float64(spotSession.player.BytesSent()) * 20.0 * float64(time.Millisecond)).Round(time.Second)
-	totalDuration := np.Duration().Round(time.Second)
-	elapsedPercent := elapsedDuration.Seconds() / totalDuration.Seconds()
-	if elapsedPercent > 1 {
-		elapsedPercent = 1
+	view := idSplit[2]
+	page, err := strconv.Atoi(idSplit[4])
+	if err != nil {
+		logger.Error("message component data interaction response had an invalid page",
+			slog.String("custom_id", i.MessageComponentData().CustomID),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
 	}
 
-	message := "```"
-	message += "Currently playing:\n"
-	message += fmt.Sprintf("  %s%s - %s (@%s)\n", np.Name(), remix, np.Artist(), np.Metadata()["requesterName"])
-	message += fmt.Sprintf("  <%s%s> [%s/%s]\n",
-		strings.Repeat("\u2588", int(elapsedPercent*30)),
-		strings.Repeat("\u2591", int(30-(elapsedPercent*30))),
-		elapsedDuration.String(),
-		totalDuration.String(),
-	)
+	// The library isn't tied to a session, so it can be paged through from anywhere
+	if view == filesView {
+		embed, components, ok := p.renderFilesPage(i.GuildID, page)
+		if !ok {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Type(discordgo.InteractionResponseUpdateMessage).
+				Message(p.config.FilesCommand.Responses.NoFiles).
+				Embeds().
+				Components().
+				SendWithLog(logger)
+			return
+		}
 
-	tracks := spotSession.player.List(false)
-	if len(tracks) >= 1 {
-		message += "Up next:\n"
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Type(discordgo.InteractionResponseUpdateMessage).
+			Embeds(embed).
+			Components(components...).
+			SendWithLog(logger)
+		return
 	}
-	for index, t := range tracks {
-		if index >= 25 {
-			message += fmt.Sprintf("...(+ %d more)", len(tracks)-index+1)
-			break
-		} else {
-			message += fmt.Sprintf("  %d) %s%s - %s (@%s)\n", index+1, t.Name(), remix, t.Artist(), t.Metadata()["requesterName"])
-		}
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	embed, components, ok := spotSession.renderPage(view, page)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Type(discordgo.InteractionResponseUpdateMessage).
+			Message(p.config.GlobalResponses.EmptyQueue).
+			Embeds().
+			Components().
+			SendWithLog(logger)
+		return
 	}
-	message += "```"
 
 	utils.InteractionResponse(discordSession, i.Interaction).
-		Ephemeral().
-		Message(message).
-		EditWithLog(logger)
+		Type(discordgo.InteractionResponseUpdateMessage).
+		Embeds(embed).
+		Components(components...).
+		SendWithLog(logger)
 }
 
 func (p *Plugin) resumeHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -770,6 +1164,7 @@ func (p *Plugin) resumeHandler(discordSession *discordgo.Session, i *discordgo.I
 	}
 
 	spotSession.player.Play()
+	spotSession.panelChanged()
 
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
@@ -801,6 +1196,7 @@ func (p *Plugin) pauseHandler(discordSession *discordgo.Session, i *discordgo.In
 	}
 
 	spotSession.player.Pause()
+	spotSession.panelChanged()
 
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
@@ -833,7 +1229,12 @@ func (p *Plugin) nextHandler(discordSession *discordgo.Session, i *discordgo.Int
 	}
 
 	userId := utils.GetInteractionUserId(i.Interaction)
-	if strings.ToLower(p.config.RestrictSkips) == "true" && !spotSession.checkPermissions(t, userId) {
+	if !p.canSkip(spotSession, t, userId) {
+		if strings.ToLower(p.config.VoteSkips) == "true" {
+			p.voteSkip(discordSession, i, spotSession, t, logger)
+			return
+		}
+
 		logger.Debug("user tried to skip a track they don't own",
 			slog.String("author_id", t.Metadata()["requesterId"]),
 			slog.String("track", t.Name()),
@@ -846,7 +1247,7 @@ func (p *Plugin) nextHandler(discordSession *discordgo.Session, i *discordgo.Int
 	}
 
 	logger.Debug("user skipped track", slog.String("track", t.Name()))
-	spotSession.player.Next()
+	spotSession.skip(t)
 
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
@@ -854,228 +1255,167 @@ func (p *Plugin) nextHandler(discordSession *discordgo.Session, i *discordgo.Int
 		SendWithLog(logger)
 }
 
-func (p *Plugin) previousHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
-	logger := p.logger.With(
-		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
-		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
-	)
+// canSkip reports whether userId can skip t outright. The requester and admins always can, everyone else needs skips to
+// be unrestricted with voting disabled.
+func (p *Plugin) canSkip(spotSession *session, t apollo.Playable, userId string) bool {
+	if spotSession.checkPermissions(t, userId) {
+		return true
+	}
 
-	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
-	if !ok {
+	return strings.ToLower(p.config.VoteSkips) != "true" && strings.ToLower(p.config.RestrictSkips) != "true"
+}
+
+// voteSkip registers a vote to skip t from the user behind i. The first vote for a track posts a live tally that
+// everyone else can vote from, and t is skipped once enough of the voice channel has voted.
+func (p *Plugin) voteSkip(discordSession *discordgo.Session, i *discordgo.InteractionCreate, spotSession *session, t apollo.Playable, logger *slog.Logger) {
+	listeners, err := spotSession.listeners(discordSession)
+	if err != nil {
+		logger.Error("failed to get voice channel listeners", slog.String("error", err.Error()))
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.GlobalResponses.NotInVoice).
+			Message(p.config.GlobalResponses.GenericError).
 			SendWithLog(logger)
 		return
 	}
 
-	if len(spotSession.player.List(true)) == 0 {
+	userId := utils.GetInteractionUserId(i.Interaction)
+	if !slices.Contains(listeners, userId) {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.PreviousCommand.Responses.EmptyQueue).
+			Message(p.config.NextCommand.Responses.NotListening).
 			SendWithLog(logger)
 		return
 	}
 
-	// If something is currently playing, verify permissions if applicable.
-	if t, ok := spotSession.player.NowPlaying(); ok {
-		userId := utils.GetInteractionUserId(i.Interaction)
-		if p.config.RestrictSkips == "true" && !spotSession.checkPermissions(t, userId) {
-			logger.Debug("user tried to skip a track they don't own",
-				slog.String("author_id", t.Metadata()["requesterId"]),
-				slog.String("track", t.Name()),
-			)
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.GlobalResponses.PermissionDenied).
-				SendWithLog(logger)
-			return
-		}
-	}
-
-	logger.Debug("player changed to previous track")
-	spotSession.player.Previous()
+	needed := votesNeeded(len(listeners), p.config.VoteSkipFraction)
 
-	utils.InteractionResponse(discordSession, i.Interaction).
-		Ephemeral().
-		Message(p.config.PreviousCommand.Responses.PreviousSuccess).
-		SendWithLog(logger)
-}
+	// Everything about the vote itself is settled in one go, so two votes at once can't both start a new vote or both
+	// skip the track
+	spotSession.voteMu.Lock()
+	vote := spotSession.skipVote
+	var expired *skipVote
+	newVote := vote == nil || vote.playable != t
+	if newVote {
+		expired = vote
+		vote = &skipVote{playable: t}
+		spotSession.skipVote = vote
+	}
 
-func (p *Plugin) removeHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
-	logger := p.logger.With(
-		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
-		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
-	)
+	cast := vote.cast(userId)
+	votes := vote.count(listeners)
+	passed := cast && votes >= needed
+	if passed {
+		spotSession.skipVote = nil
+	}
+	spotSession.voteMu.Unlock()
 
-	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
-	if !ok {
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.GlobalResponses.NotInVoice).
-			SendWithLog(logger)
-		return
+	// Whatever was left of the previous vote no longer applies
+	if expired != nil {
+		if err = expired.update(discordSession, p.config.NextCommand.Responses.VoteExpired, nil); err != nil {
+			logger.Error("failed to expire skip vote", slog.String("error", err.Error()))
+		}
 	}
 
-	queue := spotSession.player.List(false)
-	if len(queue) == 0 {
+	if !cast {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.GlobalResponses.EmptyQueue).
+			Message(p.config.NextCommand.Responses.AlreadyVoted).
 			SendWithLog(logger)
 		return
 	}
 
-	utils.InteractionResponse(discordSession, i.Interaction).
-		Ephemeral().
-		Deferred().
-		SendWithLog(logger)
-
-	removeOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "remove")
-	if removeOption == nil {
+	logger.Debug("user voted to skip track",
+		slog.String("track", t.Name()),
+		slog.Int("votes", votes),
+		slog.Int("needed", needed),
+	)
 
-		logger.Error("unexpected command data found for command",
-			slog.String("expected", "spotify remove [...]"),
-		)
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.GlobalResponses.GenericError).
-			EditWithLog(logger)
-		return
+	message := fmt.Sprintf("%s **%s** (%d/%d)", p.config.NextCommand.Responses.VotePrompt, t.Name(), votes, needed)
+	var components []discordgo.MessageComponent
+	if passed {
+		message = fmt.Sprintf("%s **%s**", p.config.NextCommand.Responses.VotePassed, t.Name())
+		spotSession.skip(t)
+	} else {
+		button := utils.Button().Id("spotify_vote_skip").Label(fmt.Sprintf("Skip (%d/%d)", votes, needed))
+		components = append(components, utils.ActionsRow().Button(button.Build()).Build())
 	}
 
-	positionOption := utils.GetCommandOption(*removeOption, "remove", "position")
-	if positionOption == nil {
-		logger.Error("required field not set", slog.String("field", "position"))
+	if newVote {
 		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.GlobalResponses.GenericError).
-			EditWithLog(logger)
-		return
-	}
+			Components(components...).
+			Message(message).
+			SendWithLog(logger)
 
-	position := int(positionOption.IntValue())
-	if position <= 0 || position > len(queue) {
-		logger.Error("invalid position value", slog.Int("position", position))
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.RemoveCommand.Responses.InvalidPosition).
-			EditWithLog(logger)
+		if tally, err := discordSession.InteractionResponse(i.Interaction); err != nil {
+			logger.Error("failed to get skip vote message", slog.String("error", err.Error()))
+		} else {
+			vote.posted(tally)
+		}
 		return
 	}
 
-	if !spotSession.checkPermissions(queue[position-1], utils.GetInteractionUserId(i.Interaction)) {
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.GlobalResponses.PermissionDenied).
-			EditWithLog(logger)
-		return
+	if err = vote.update(discordSession, message, components); err != nil {
+		logger.Error("failed to update skip vote", slog.String("error", err.Error()))
 	}
 
-	removed := spotSession.player.Get(spotSession.player.Cursor() + position - 1)
-	spotSession.player.Remove(spotSession.player.Cursor() + position - 1)
-	logger.Debug("user removed track",
-		slog.Int("position", position),
-		slog.Any("title", removed.Name()),
-	)
-
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
-		Message(p.config.RemoveCommand.Responses.RemoveSuccess).
-		EditWithLog(logger)
+		Message(p.config.NextCommand.Responses.VoteRecorded).
+		SendWithLog(logger)
 }
 
-func (p *Plugin) loginHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+func (p *Plugin) voteMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	logger := p.logger.With(
-		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
 		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
 	)
 
-	// If the session for the guild doesn't already exist, create it.
 	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
 	if !ok {
-		sessionConfig := spotify.DefaultSessionConfig()
-		sessionConfig.ConfigHomeDir = filepath.Join(sessionConfig.ConfigHomeDir, i.Interaction.GuildID)
-		sessionConfig.OAuthCallback = p.config.SpotifyCallbackUrl
-		spotSession = newSession(i.Interaction.GuildID, sessionConfig, p.logger.Handler(), p.config.AdminIds...)
-		p.sessions.Set(i.Interaction.GuildID, spotSession)
-	}
-
-	if spotSession.session.LoggedIn() {
-		yesButton := utils.Button().Label("Yes").Id("spotify_login_yes").Build()
-		noButton := utils.Button().Style(discordgo.SecondaryButton).Label("No").Id("spotify_login_no").Build()
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Components(utils.ActionsRow().Button(yesButton).Button(noButton).Build()).
-			Message(p.config.LoginCommand.Responses.AlreadyLoggedIn).
+			Message(p.config.GlobalResponses.NotInVoice).
 			SendWithLog(logger)
 		return
 	}
 
-	if err := spotSession.session.Login("georgetuney"); err == nil {
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(p.config.LoginCommand.Responses.LoginSuccess).
+	// Votes only ever apply to the track they were started for
+	t, ok := spotSession.player.NowPlaying()
+	spotSession.voteMu.Lock()
+	vote := spotSession.skipVote
+	spotSession.voteMu.Unlock()
+	if !ok || vote == nil || vote.playable != t {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Type(discordgo.InteractionResponseUpdateMessage).
+			Components().
+			Message(p.config.NextCommand.Responses.VoteExpired).
 			SendWithLog(logger)
-
-		p.logger = p.logger.With(slog.String("spotify_user", spotSession.session.Username()))
 		return
 	}
 
-	url := spotify.StartLocalOAuth(p.config.SpotifyClientId, p.config.SpotifyClientSecret, p.config.SpotifyCallbackUrl)
-
-	linkButton := utils.Button().Style(discordgo.LinkButton).Label("Login").URL(url).Build()
-	utils.InteractionResponse(discordSession, i.Interaction).
-		Ephemeral().
-		Components(utils.ActionsRow().Button(linkButton).Build()).
-		Message(p.config.LoginCommand.Responses.LoginPrompt).
-		SendWithLog(logger)
-
-	go func() {
-		token := spotify.GetOAuthToken()
-		if err := spotSession.session.LoginWithToken("georgetuney", token); err != nil {
-			logger.Error("spotify login failed", slog.String("error", err.Error()))
-
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.LoginCommand.Responses.LoginFail).
-				FollowUpCreateWithLog(logger)
-		} else {
-			logger.Info("spotify login succeeded", slog.String("spotify_user", spotSession.session.Username()))
-
-			p.logger = p.logger.With(slog.String("spotify_user", spotSession.session.Username()))
-
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.LoginCommand.Responses.LoginSuccess).
-				FollowUpCreateWithLog(logger)
-		}
-	}()
+	p.voteSkip(discordSession, i, spotSession, t, logger)
 }
 
-func (p *Plugin) loginMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+func (p *Plugin) panelMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	logger := p.logger.With(
 		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
 		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
 	)
 
-	// If the session for the guild doesn't already exist, create it.
 	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
-	if !ok {
-		sessionConfig := spotify.DefaultSessionConfig()
-		sessionConfig.ConfigHomeDir = filepath.Join(sessionConfig.ConfigHomeDir, i.Interaction.GuildID)
-		sessionConfig.OAuthCallback = p.config.SpotifyCallbackUrl
-		spotSession = newSession(i.Interaction.GuildID, sessionConfig, p.logger.Handler(), p.config.AdminIds...)
-		p.sessions.Set(i.Interaction.GuildID, spotSession)
+	if !ok || !spotSession.hasPanel() {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
 	}
 
-	messageData := i.MessageComponentData()
-	idSplit := strings.Split(messageData.CustomID, "_")
+	idSplit := strings.Split(i.MessageComponentData().CustomID, "_")
 	if len(idSplit) != 3 {
 		logger.Error("message component data interaction response had an unknown custom ID",
-			slog.String("custom_id", messageData.CustomID),
+			slog.String("custom_id", i.MessageComponentData().CustomID),
 		)
-
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
 			Message(p.config.GlobalResponses.GenericError).
@@ -1083,91 +1423,154 @@ func (p *Plugin) loginMessageHandler(discordSession *discordgo.Session, i *disco
 		return
 	}
 
-	action := idSplit[2]
+	userId := utils.GetInteractionUserId(i.Interaction)
+	t, playing := spotSession.player.NowPlaying()
+
+	switch idSplit[2] {
+	case "pause":
+		spotSession.player.Pause()
+	case "resume":
+		spotSession.player.Play()
+	case "next":
+		if !playing {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.EmptyQueue).
+				SendWithLog(logger)
+			return
+		}
 
-	if action == "yes" {
-		url := spotify.StartLocalOAuth(p.config.SpotifyClientId, p.config.SpotifyClientSecret, p.config.SpotifyCallbackUrl)
+		if !p.canSkip(spotSession, t, userId) {
+			if strings.ToLower(p.config.VoteSkips) == "true" {
+				p.voteSkip(discordSession, i, spotSession, t, logger)
+				return
+			}
 
-		linkButton := utils.Button().Style(discordgo.LinkButton).Label("Login").URL(url).Build()
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Components(utils.ActionsRow().Button(linkButton).Build()).
-			Message(p.config.LoginCommand.Responses.LoginPrompt).
-			SendWithLog(logger)
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.PermissionDenied).
+				SendWithLog(logger)
+			return
+		}
 
-		go func() {
-			token := spotify.GetOAuthToken()
-			if err := spotSession.session.LoginWithToken("georgetuney", token); err != nil {
-				utils.InteractionResponse(discordSession, i.Interaction).
-					Ephemeral().
-					Message(p.config.LoginCommand.Responses.LoginFail).
-					FollowUpCreateWithLog(logger)
-			} else {
-				utils.InteractionResponse(discordSession, i.Interaction).
-					Ephemeral().
-					Message(p.config.LoginCommand.Responses.LoginSuccess).
-					FollowUpCreateWithLog(logger)
-				p.logger = p.logger.With(slog.String("spotify_user", spotSession.session.Username()))
-			}
-		}()
-	} else if action == "no" {
+		logger.Debug("user skipped track", slog.String("track", t.Name()))
+		spotSession.skip(t)
+	case "previous":
+		if playing && p.config.RestrictSkips == "true" && !spotSession.checkPermissions(t, userId) {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.PermissionDenied).
+				SendWithLog(logger)
+			return
+		}
+
+		logger.Debug("player changed to previous track")
+		spotSession.player.Previous()
+	case "shuffle":
+		spotSession.shuffle()
+		p.rebalanceQueue(spotSession)
+	case "loop":
+		spotSession.loopMode = nextLoopMode(spotSession.loopMode)
+		logger.Debug("user changed loop mode", slog.String("mode", spotSession.loopMode))
+	default:
+		logger.Error("interaction received unknown panel action", slog.String("action", idSplit[2]))
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.LoginCommand.Responses.LoginCancel).
+			Message(p.config.GlobalResponses.GenericError).
 			SendWithLog(logger)
+		return
 	}
+
+	spotSession.panelChanged()
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		DeferredUpdate().
+		SendWithLog(logger)
 }
 
-func (p *Plugin) quizHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+func (p *Plugin) previousHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	logger := p.logger.With(
 		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
 		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
 	)
 
-	// If the session for the guild doesn't already exist, create it.
 	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
 	if !ok {
-		sessionConfig := spotify.DefaultSessionConfig()
-		sessionConfig.ConfigHomeDir = filepath.Join(sessionConfig.ConfigHomeDir, i.Interaction.GuildID)
-		sessionConfig.OAuthCallback = p.config.SpotifyCallbackUrl
-		spotSession = newSession(i.Interaction.GuildID, sessionConfig, p.logger.Handler(), p.config.AdminIds...)
-		p.sessions.Set(i.Interaction.GuildID, spotSession)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
 	}
 
-	if !spotSession.session.LoggedIn() {
-		if err := spotSession.session.Login("georgetuney"); err != nil {
+	if len(spotSession.player.List(true)) == 0 {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.PreviousCommand.Responses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
+	// If something is currently playing, verify permissions if applicable.
+	if t, ok := spotSession.player.NowPlaying(); ok {
+		userId := utils.GetInteractionUserId(i.Interaction)
+		if p.config.RestrictSkips == "true" && !spotSession.checkPermissions(t, userId) {
+			logger.Debug("user tried to skip a track they don't own",
+				slog.String("author_id", t.Metadata()["requesterId"]),
+				slog.String("track", t.Name()),
+			)
 			utils.InteractionResponse(discordSession, i.Interaction).
 				Ephemeral().
-				Message(p.config.GlobalResponses.NotLoggedIn).
+				Message(p.config.GlobalResponses.PermissionDenied).
 				SendWithLog(logger)
 			return
 		}
 	}
 
-	if spotSession.quizGame != nil {
+	logger.Debug("player changed to previous track")
+	spotSession.player.Previous()
+	spotSession.panelChanged()
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(p.config.PreviousCommand.Responses.PreviousSuccess).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) removeHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message("Game is already running!").
+			Message(p.config.GlobalResponses.NotInVoice).
 			SendWithLog(logger)
 		return
 	}
 
-	if spotSession.voiceConnection == nil {
+	queue := spotSession.player.List(false)
+	if len(queue) == 0 {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.GlobalResponses.NotInVoice).
+			Message(p.config.GlobalResponses.EmptyQueue).
 			SendWithLog(logger)
 		return
 	}
 
 	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
 		Deferred().
 		SendWithLog(logger)
 
-	quizOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "quiz")
-	if quizOption == nil {
+	removeOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "remove")
+	if removeOption == nil {
+
 		logger.Error("unexpected command data found for command",
-			slog.String("expected", "spotify quiz [...]"),
+			slog.String("expected", "spotify remove [...]"),
 		)
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
@@ -1176,303 +1579,1715 @@ func (p *Plugin) quizHandler(discordSession *discordgo.Session, i *discordgo.Int
 		return
 	}
 
-	// Set defaults and try and fetch options
-	playlist := ""
-	questions := 10
-	for _, option := range quizOption.Options {
-		switch option.Name {
-		case "playlist":
-			playlist, _ = option.Value.(string)
-		case "questions":
-			v, _ := option.Value.(float64)
-			questions = int(v)
-		default:
-			logger.Error("interaction received unknown option", slog.String("option", option.Name))
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.GlobalResponses.GenericError).
-				EditWithLog(logger)
-			return
-		}
-	}
-
-	results, err := spotSession.session.Search(playlist).Limit(1).Playlists()
-	if err != nil || len(results) == 0 {
-		logger.Error("failed to search playlist", slog.String("error", err.Error()))
+	positionOption := utils.GetCommandOption(*removeOption, "remove", "position")
+	if positionOption == nil {
+		logger.Error("required field not set", slog.String("field", "position"))
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message("I had trouble finding that playlist :/").
+			Message(p.config.GlobalResponses.GenericError).
 			EditWithLog(logger)
 		return
 	}
 
-	trackIds := results[0].TrackIds()
-	if len(trackIds) < 5 {
+	position := int(positionOption.IntValue())
+	if position <= 0 || position > len(queue) {
+		logger.Error("invalid position value", slog.Int("position", position))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.RemoveCommand.Responses.InvalidPosition).
+			EditWithLog(logger)
+		return
+	}
+
+	if !spotSession.checkPermissions(queue[position-1], utils.GetInteractionUserId(i.Interaction)) {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.PermissionDenied).
+			EditWithLog(logger)
+		return
+	}
+
+	removed := spotSession.player.Get(spotSession.player.Cursor() + position - 1)
+	spotSession.player.Remove(spotSession.player.Cursor() + position - 1)
+	p.rebalanceQueue(spotSession)
+	logger.Debug("user removed track",
+		slog.Int("position", position),
+		slog.Any("title", removed.Name()),
+	)
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(p.config.RemoveCommand.Responses.RemoveSuccess).
+		EditWithLog(logger)
+}
+
+func (p *Plugin) moveHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	queue := spotSession.player.List(false)
+	if len(queue) == 0 {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
+	moveOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "move")
+	if moveOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify move [...]"),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	fromOption := utils.GetCommandOption(*moveOption, "move", "from")
+	if fromOption == nil {
+		logger.Error("required field not set", slog.String("field", "from"))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+	from := int(fromOption.IntValue())
+
+	// Moving without a destination plays the song(s) next
+	to := 1
+	if toOption := utils.GetCommandOption(*moveOption, "move", "to"); toOption != nil {
+		to = int(toOption.IntValue())
+	}
+
+	count := 1
+	if countOption := utils.GetCommandOption(*moveOption, "move", "count"); countOption != nil {
+		count = int(countOption.IntValue())
+	}
+
+	if from <= 0 || count <= 0 || from+count-1 > len(queue) || to <= 0 || to+count-1 > len(queue) {
+		logger.Debug("invalid position value",
+			slog.Int("from", from),
+			slog.Int("to", to),
+			slog.Int("count", count),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.MoveCommand.Responses.InvalidPosition).
+			SendWithLog(logger)
+		return
+	}
+
+	userId := utils.GetInteractionUserId(i.Interaction)
+	for _, t := range queue[from-1 : from+count-1] {
+		if !spotSession.checkPermissions(t, userId) {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.PermissionDenied).
+				SendWithLog(logger)
+			return
+		}
+	}
+
+	cursor := spotSession.player.Cursor()
+	spotSession.moveRange(cursor+from-1, cursor+to-1, count)
+	logger.Debug("user moved tracks",
+		slog.Int("from", from),
+		slog.Int("to", to),
+		slog.Int("count", count),
+	)
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(p.config.MoveCommand.Responses.MoveSuccess).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) banHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	if !slices.Contains(p.config.AdminIds, utils.GetInteractionUserId(i.Interaction)) {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.PermissionDenied).
+			SendWithLog(logger)
+		return
+	}
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	np, ok := spotSession.player.NowPlaying()
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
+	t, ok := np.(*track)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.BanCommand.Responses.NotBannable).
+			SendWithLog(logger)
+		return
+	}
+
+	// Both subcommands share a handler, the name decides which way the buttons go
+	action := i.ApplicationCommandData().Options[0].Name
+	label := "Ban"
+	if action == "unban" {
+		label = "Unban"
+	}
+
+	row := utils.ActionsRow().Button(
+		utils.Button().
+			Id(fmt.Sprintf("spotify_%s_track_%s", action, t.Id())).
+			Label(fmt.Sprintf("%s song", label)).
+			Style(discordgo.DangerButton).
+			Build(),
+	)
+
+	// Only the first artist gets a button, which is the one shown everywhere else too
+	if details, ok := p.trackDetails(t.Track); ok && len(details.Artists) > 0 {
+		row.Button(
+			utils.Button().
+				Id(fmt.Sprintf("spotify_%s_artist_%s", action, details.Artists[0].Id)).
+				Label(fmt.Sprintf("%s artist", label)).
+				Style(discordgo.DangerButton).
+				Build(),
+		)
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Components(row.Build()).
+		Message(fmt.Sprintf("```Name: %s\nArtist: %s\n```", t.Name(), t.Artist())).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) banMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	if !slices.Contains(p.config.AdminIds, utils.GetInteractionUserId(i.Interaction)) {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.PermissionDenied).
+			SendWithLog(logger)
+		return
+	}
+
+	idSplit := strings.Split(i.MessageComponentData().CustomID, "_")
+	if len(idSplit) != 4 {
+		logger.Error("message component data interaction response had an unknown custom ID",
+			slog.String("custom_id", i.MessageComponentData().CustomID),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	action, target, id := idSplit[1], idSplit[2], idSplit[3]
+
+	if target != "track" && target != "artist" {
+		logger.Error("interaction received unknown ban target", slog.String("target", target))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	err := p.bans.update(i.Interaction.GuildID, func(bans *guildBans) {
+		banned := &bans.Tracks
+		if target == "artist" {
+			banned = &bans.Artists
+		}
+
+		if action == "unban" {
+			*banned = slices.DeleteFunc(*banned, func(s string) bool { return s == id })
+		} else if !slices.Contains(*banned, id) {
+			*banned = append(*banned, id)
+		}
+	})
+	if err != nil {
+		logger.Error("failed to save bans", slog.String("error", err.Error()))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	message := p.config.BanCommand.Responses.BanSuccess
+	if action == "unban" {
+		message = p.config.BanCommand.Responses.UnbanSuccess
+	}
+	logger.Debug("user updated bans",
+		slog.String("action", action),
+		slog.String("target", target),
+		slog.String("id", id),
+	)
+
+	// There's no point letting whatever just got banned keep playing
+	if spotSession, ok := p.sessions.Get(i.Interaction.GuildID); ok && action == "ban" {
+		if np, ok := spotSession.player.NowPlaying(); ok && p.isBanned(i.Interaction.GuildID, np) {
+			spotSession.player.Next()
+		}
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Type(discordgo.InteractionResponseUpdateMessage).
+		Components().
+		Message(message).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) loginHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	// If the session for the guild doesn't already exist, create it.
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		spotSession = p.createSession(i.Interaction.GuildID)
+	}
+
+	if spotSession.session.LoggedIn() {
+		yesButton := utils.Button().Label("Yes").Id("spotify_login_yes").Build()
+		noButton := utils.Button().Style(discordgo.SecondaryButton).Label("No").Id("spotify_login_no").Build()
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Components(utils.ActionsRow().Button(yesButton).Button(noButton).Build()).
+			Message(p.config.LoginCommand.Responses.AlreadyLoggedIn).
+			SendWithLog(logger)
+		return
+	}
+
+	if err := spotSession.session.Login("georgetuney"); err == nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.LoginCommand.Responses.LoginSuccess).
+			SendWithLog(logger)
+
+		p.logger = p.logger.With(slog.String("spotify_user", spotSession.session.Username()))
+
+		// The snapshot couldn't be restored without a login, so try again before anything gets queued
+		if err = p.restoreSession(spotSession); err != nil {
+			logger.Error("failed to restore queue snapshot", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	url := spotify.StartLocalOAuth(p.config.SpotifyClientId, p.config.SpotifyClientSecret, p.config.SpotifyCallbackUrl)
+
+	linkButton := utils.Button().Style(discordgo.LinkButton).Label("Login").URL(url).Build()
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Components(utils.ActionsRow().Button(linkButton).Build()).
+		Message(p.config.LoginCommand.Responses.LoginPrompt).
+		SendWithLog(logger)
+
+	go func() {
+		token := spotify.GetOAuthToken()
+		if err := spotSession.session.LoginWithToken("georgetuney", token); err != nil {
+			logger.Error("spotify login failed", slog.String("error", err.Error()))
+
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.LoginCommand.Responses.LoginFail).
+				FollowUpCreateWithLog(logger)
+		} else {
+			logger.Info("spotify login succeeded", slog.String("spotify_user", spotSession.session.Username()))
+
+			p.logger = p.logger.With(slog.String("spotify_user", spotSession.session.Username()))
+
+			if err = p.restoreSession(spotSession); err != nil {
+				logger.Error("failed to restore queue snapshot", slog.String("error", err.Error()))
+			}
+
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.LoginCommand.Responses.LoginSuccess).
+				FollowUpCreateWithLog(logger)
+		}
+	}()
+}
+
+func (p *Plugin) loginMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	// If the session for the guild doesn't already exist, create it.
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		spotSession = p.createSession(i.Interaction.GuildID)
+	}
+
+	messageData := i.MessageComponentData()
+	idSplit := strings.Split(messageData.CustomID, "_")
+	if len(idSplit) != 3 {
+		logger.Error("message component data interaction response had an unknown custom ID",
+			slog.String("custom_id", messageData.CustomID),
+		)
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	action := idSplit[2]
+
+	if action == "yes" {
+		url := spotify.StartLocalOAuth(p.config.SpotifyClientId, p.config.SpotifyClientSecret, p.config.SpotifyCallbackUrl)
+
+		linkButton := utils.Button().Style(discordgo.LinkButton).Label("Login").URL(url).Build()
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Components(utils.ActionsRow().Button(linkButton).Build()).
+			Message(p.config.LoginCommand.Responses.LoginPrompt).
+			SendWithLog(logger)
+
+		go func() {
+			token := spotify.GetOAuthToken()
+			if err := spotSession.session.LoginWithToken("georgetuney", token); err != nil {
+				utils.InteractionResponse(discordSession, i.Interaction).
+					Ephemeral().
+					Message(p.config.LoginCommand.Responses.LoginFail).
+					FollowUpCreateWithLog(logger)
+			} else {
+				utils.InteractionResponse(discordSession, i.Interaction).
+					Ephemeral().
+					Message(p.config.LoginCommand.Responses.LoginSuccess).
+					FollowUpCreateWithLog(logger)
+				p.logger = p.logger.With(slog.String("spotify_user", spotSession.session.Username()))
+			}
+		}()
+	} else if action == "no" {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.LoginCommand.Responses.LoginCancel).
+			SendWithLog(logger)
+	}
+}
+
+func (p *Plugin) quizHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	// If the session for the guild doesn't already exist, create it.
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		spotSession = p.createSession(i.Interaction.GuildID)
+	}
+
+	if !spotSession.session.LoggedIn() {
+		if err := spotSession.session.Login("georgetuney"); err != nil {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.NotLoggedIn).
+				SendWithLog(logger)
+			return
+		}
+	}
+
+	if spotSession.quizGame != nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message("Game is already running!").
+			SendWithLog(logger)
+		return
+	}
+
+	if spotSession.voiceConnection == nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Deferred().
+		SendWithLog(logger)
+
+	quizOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "quiz")
+	if quizOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify quiz [...]"),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			EditWithLog(logger)
+		return
+	}
+
+	// Set defaults and try and fetch options
+	playlist := ""
+	questions := 10
+	for _, option := range quizOption.Options {
+		switch option.Name {
+		case "playlist":
+			playlist, _ = option.Value.(string)
+		case "questions":
+			v, _ := option.Value.(float64)
+			questions = int(v)
+		default:
+			logger.Error("interaction received unknown option", slog.String("option", option.Name))
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.GenericError).
+				EditWithLog(logger)
+			return
+		}
+	}
+
+	results, err := spotSession.session.Search(playlist).Limit(1).Playlists()
+	if err != nil || len(results) == 0 {
+		logger.Error("failed to search playlist", slog.String("error", err.Error()))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message("I had trouble finding that playlist :/").
+			EditWithLog(logger)
+		return
+	}
+
+	trackIds := results[0].TrackIds()
+	if len(trackIds) < 5 {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message("Playlist too small for a quiz game.").
+			EditWithLog(logger)
+		return
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	quizGame := &quiz{
+		playlist:          trackIds,
+		questionNumber:    1,
+		previousQuestions: threadsafe.NewMap[int, bool](),
+
+		questions: questions,
+		scoreboard: threadsafe.NewMap[string, struct {
+			score     int
+			totalTime float64
+		}](),
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		cancelFunc: cancelFunc,
+		banned: func(t spotify.Track) bool {
+			return p.isBanned(i.GuildID, t)
+		},
+	}
+
+	spotSession.quizGame = quizGame
+
+	userId := utils.GetInteractionUserId(i.Interaction)
+	quizGame.startMessage = fmt.Sprintf("<@%s> started a spotify quiz game! Click the button to join.\n", userId)
+	quizGame.startMessage += fmt.Sprintf("Category: `%s`", results[0].Name())
+	buttonBuilder := utils.Button().Id("spotify_quiz_join").Label("Join game")
+	components := utils.ActionsRow().Button(buttonBuilder.Build()).Build()
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Components(components).
+		Message(quizGame.startMessage).
+		EditWithLog(logger)
+
+	quizGame.startInteraction = i.Interaction
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(15 * time.Second):
+			break
+		}
+
+		// Close out the join option
+		message := fmt.Sprintf("%s\n```", spotSession.quizGame.startMessage)
+		for _, user := range spotSession.quizGame.scoreboard.Keys() {
+			message += fmt.Sprintf("%s joined.\n", user)
+		}
+		message += "```"
+		components = utils.ActionsRow().Button(buttonBuilder.Enabled(false).Build()).Build()
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Message(message).
+			Components(components).
+			EditWithLog(logger)
+
+		if len(quizGame.scoreboard.Data) == 0 {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Message("No one joined :disappointed:").
+				FollowUpCreateWithLog(logger)
+			spotSession.quizGame = nil
+			return
+		}
+
+		for index := 0; index < quizGame.questions; index++ {
+			if spotSession.quizGame == nil {
+				return
+			} else if len(quizGame.playlist) < 5 {
+				break
+			}
+
+			quizGame.questionAnswer = quizGame.rng.Intn(5)
+			tracks := quizGame.getRandomTracks(spotSession.session, 5)
+			if len(tracks) < 5 {
+				break
+			}
+			quizGame.questionAnswerTrack = tracks[quizGame.questionAnswer]
+
+			trackIndex := slices.Index(quizGame.playlist, quizGame.questionAnswerTrack.Id())
+			if trackIndex != -1 {
+				quizGame.playlist = slices.Delete(quizGame.playlist, trackIndex, trackIndex+1)
+			}
+
+			quizGame.questionResponseTimes = threadsafe.NewMap[string, float64]()
+			for _, username := range quizGame.scoreboard.Keys() {
+				quizGame.questionResponseTimes.Set(username, 16.0)
+			}
+
+			t := track{
+				Track: tracks[quizGame.questionAnswer],
+				metadata: map[string]string{
+					"requesterId":   "george",
+					"requesterName": "george",
+					"frequency":     fmt.Sprintf("%d", discordFrequency),
+				},
+			}
+			spotSession.player.Enqueue(&t)
+			spotSession.player.Play()
+			quizGame.questionStartTime = time.Now()
+			questionMessage, err := utils.InteractionResponse(discordSession, i.Interaction).
+				Response(quizGame.generateQuestion(tracks)).
+				FollowUpCreate()
+			if err != nil {
+				logger.Error("failed to create followup", slog.String("error", err.Error()))
+			}
+
+			go func() {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(15 * time.Second):
+					break
+				}
+
+				spotSession.player.Next()
+
+				utils.InteractionResponse(discordSession, i.Interaction).
+					Components().
+					FollowUpEditWithLog(questionMessage.ID, logger)
+
+				utils.InteractionResponse(discordSession, i.Interaction).
+					Message(quizGame.generateQuestionWinner()).
+					FollowUpCreateWithLog(logger)
+			}()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(18 * time.Second):
+				break
+			}
+
+			quizGame.questionNumber++
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Message(quizGame.generateGameWinner()).
+			FollowUpCreateWithLog(logger)
+
+		spotSession.quizGame = nil
+	}()
+}
+
+func (p *Plugin) quizMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok || spotSession.quizGame == nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message("Game no longer exists.").SendWithLog(logger)
+		return
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Deferred().
+		SendWithLog(logger)
+
+	messageData := i.MessageComponentData()
+	switch {
+	case strings.HasPrefix(messageData.CustomID, "spotify_quiz_join"):
+		username := utils.GetInteractionUserName(i.Interaction)
+
+		if _, ok = spotSession.quizGame.scoreboard.Get(username); ok {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message("gl;hf").
+				EditWithLog(logger)
+			return
+		}
+
+		spotSession.quizGame.scoreboard.Set("@"+username, struct {
+			score     int
+			totalTime float64
+		}{score: 0, totalTime: 0.0})
+
+		message := fmt.Sprintf("%s\n```", spotSession.quizGame.startMessage)
+		for _, user := range spotSession.quizGame.scoreboard.Keys() {
+			message += fmt.Sprintf("%s joined.\n", user)
+		}
+		message += "```"
+
+		buttonBuilder := utils.Button().Id("spotify_quiz_join").Label("Join game")
+		components := utils.ActionsRow().Button(buttonBuilder.Build()).Build()
+		utils.InteractionResponse(discordSession, spotSession.quizGame.startInteraction).
+			Components(components).
+			Message(message).EditWithLog(logger)
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message("gl;hf").
+			EditWithLog(logger)
+
+	case strings.HasPrefix(messageData.CustomID, "spotify_quiz_answer"):
+		username := fmt.Sprintf("@%s", utils.GetInteractionUserName(i.Interaction))
+
+		if _, ok = spotSession.quizGame.scoreboard.Get(username); !ok {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message("You aren't a part of this round.").
+				EditWithLog(logger)
+			return
+		}
+
+		if responseTime, _ := spotSession.quizGame.questionResponseTimes.Get(username); responseTime != 16.0 {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message("You already selected an answer for this round.").
+				EditWithLog(logger)
+			return
+		}
+
+		idSplit := strings.Split(messageData.CustomID, "_")
+		if len(idSplit) != 4 {
+			logger.Error("message component data interaction response had an unknown custom ID",
+				slog.String("custom_id", messageData.CustomID),
+			)
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.GenericError).
+				EditWithLog(logger)
+			return
+		}
+
+		answer, err := strconv.Atoi(idSplit[3])
+		if err != nil {
+			logger.Error("failed to convert id to int",
+				slog.String("error", err.Error()),
+				slog.String("id", idSplit[3]),
+			)
+
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.GenericError).
+				EditWithLog(logger)
+			return
+
+		}
+
+		timeElapsed := time.Since(spotSession.quizGame.questionStartTime).Round(time.Millisecond).Seconds()
+		if answer-1 == spotSession.quizGame.questionAnswer {
+			spotSession.quizGame.questionResponseTimes.Set(username, timeElapsed)
+
+			score, _ := spotSession.quizGame.scoreboard.Get(username)
+			score.totalTime += timeElapsed
+			spotSession.quizGame.scoreboard.Set(username, score)
+		} else {
+			spotSession.quizGame.questionResponseTimes.Set(username, 15.0)
+
+			score, _ := spotSession.quizGame.scoreboard.Get(username)
+			score.totalTime += 15.0
+			spotSession.quizGame.scoreboard.Set(username, score)
+		}
+
+		message := fmt.Sprintf("You answered in: %.3fs :stopwatch:", timeElapsed)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(message).
+			EditWithLog(logger)
+	}
+}
+
+func (p *Plugin) listifyHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	embed, components, ok := spotSession.renderPage(historyView, 0)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Embeds(embed).
+		Components(components...).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) clearHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	spotSession.player.Empty()
+	spotSession.pinned.Empty()
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(p.config.GlobalResponses.GenericSuccess).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) shuffleHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	spotSession.shuffle()
+	p.rebalanceQueue(spotSession)
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(p.config.GlobalResponses.GenericSuccess).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) loopHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	loopOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "loop")
+	if loopOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify loop [...]"),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	modeOption := utils.GetCommandOption(*loopOption, "loop", "mode")
+	if modeOption == nil {
+		logger.Error("required field not set", slog.String("field", "mode"))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	var message string
+	switch modeOption.StringValue() {
+	case loopOff:
+		message = p.config.LoopCommand.Responses.LoopOff
+	case loopTrack:
+		message = p.config.LoopCommand.Responses.LoopTrack
+	case loopQueue:
+		message = p.config.LoopCommand.Responses.LoopQueue
+	default:
+		logger.Error("interaction received unknown loop mode", slog.String("mode", modeOption.StringValue()))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	spotSession.loopMode = modeOption.StringValue()
+	logger.Debug("user changed loop mode", slog.String("mode", spotSession.loopMode))
+	spotSession.panelChanged()
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(message).
+		SendWithLog(logger)
+}
+
+func (p *Plugin) filesHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	groupOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "files")
+	if groupOption == nil || len(groupOption.Options) == 0 {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify files [...]"),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+	subOption := groupOption.Options[0]
+
+	// Anyone can look through the library, but only admins can change it
+	if subOption.Name != "list" && subOption.Name != "info" &&
+		!slices.Contains(p.config.AdminIds, utils.GetInteractionUserId(i.Interaction)) {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.PermissionDenied).
+			SendWithLog(logger)
+		return
+	}
+
+	var name string
+	if nameOption := utils.GetCommandOption(*subOption, subOption.Name, "name"); nameOption != nil {
+		name = strings.TrimSpace(nameOption.StringValue())
+	}
+
+	switch subOption.Name {
+	case "upload":
+		var attachment *discordgo.MessageAttachment
+		if fileOption := utils.GetCommandOption(*subOption, "upload", "file"); fileOption != nil {
+			if resolved := i.ApplicationCommandData().Resolved; resolved != nil {
+				attachmentId, _ := fileOption.Value.(string)
+				attachment = resolved.Attachments[attachmentId]
+			}
+		}
+
+		if attachment == nil {
+			logger.Error("required field not set", slog.String("field", "file"))
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.GlobalResponses.GenericError).
+				SendWithLog(logger)
+			return
+		}
+
+		// Downloading the file can take a while
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Deferred().
+			SendWithLog(logger)
+
+		entry, err := p.downloadToLibrary(p.guildLibrary(i.GuildID), attachment)
+		if err == nil {
+			logger.Debug("user uploaded file", slog.String("path", entry.ref()))
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.uploadOutcome(attachment, entry, err, logger)).
+			EditWithLog(logger)
+	case "list":
+		embed, components, ok := p.renderFilesPage(i.GuildID, 0)
+		if !ok {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.FilesCommand.Responses.NoFiles).
+				SendWithLog(logger)
+			return
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Embeds(embed).
+			Components(components...).
+			SendWithLog(logger)
+	case "rename":
+		var newName string
+		if newNameOption := utils.GetCommandOption(*subOption, "rename", "new_name"); newNameOption != nil {
+			newName = newNameOption.StringValue()
+		}
+
+		message := p.config.GlobalResponses.GenericError
+		if entry, err := p.renameLibraryFile(i.GuildID, name, newName); err != nil {
+			message = p.fileErrorMessage(err, logger)
+		} else {
+			logger.Debug("user renamed file", slog.String("from", name), slog.String("to", entry.ref()))
+			message = fmt.Sprintf("%s Renamed `%s` to `%s`.", p.config.FilesCommand.Responses.RenameSuccess, name, entry.ref())
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(message).
+			SendWithLog(logger)
+	case "delete":
+		message := p.config.GlobalResponses.GenericError
+		if entry, err := p.removeLibraryFile(i.GuildID, name); err != nil {
+			message = p.fileErrorMessage(err, logger)
+		} else {
+			logger.Debug("user deleted file", slog.String("path", entry.ref()))
+			message = fmt.Sprintf("%s Deleted `%s`.", p.config.FilesCommand.Responses.DeleteSuccess, entry.ref())
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(message).
+			SendWithLog(logger)
+	case "publish":
+		message := p.config.GlobalResponses.GenericError
+		if entry, err := p.publishLibraryFile(i.GuildID, name); err != nil {
+			message = p.fileErrorMessage(err, logger)
+		} else {
+			logger.Debug("user published file", slog.String("from", name), slog.String("to", entry.ref()))
+			message = fmt.Sprintf("%s Published `%s` as `%s`.", p.config.FilesCommand.Responses.PublishSuccess, name, entry.ref())
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(message).
+			SendWithLog(logger)
+	case "info":
+		entry, ok := p.findLocalFile(i.GuildID, name)
+		if !ok {
+			utils.InteractionResponse(discordSession, i.Interaction).
+				Ephemeral().
+				Message(p.config.FilesCommand.Responses.NotFound).
+				SendWithLog(logger)
+			return
+		}
+
+		embed, artwork := fileInfo(entry)
+		response := &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{embed},
+				Flags:  discordgo.MessageFlagsEphemeral,
+			},
+		}
+		if artwork != nil {
+			response.Data.Files = []*discordgo.File{artwork}
+		}
+
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Response(response).
+			SendWithLog(logger)
+	default:
+		logger.Error("interaction received unknown files subcommand", slog.String("subcommand", subOption.Name))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+	}
+}
+
+// fileErrorMessage returns the response for an error from changing the library.
+func (p *Plugin) fileErrorMessage(err error, logger *slog.Logger) string {
+	switch {
+	case errors.Is(err, errFileNotFound):
+		return p.config.FilesCommand.Responses.NotFound
+	case errors.Is(err, errFileExists):
+		return p.config.FilesCommand.Responses.AlreadyExists
+	case errors.Is(err, errFileName):
+		return p.config.FilesCommand.Responses.InvalidName
+	case errors.Is(err, errNotAudio):
+		return p.config.FilesCommand.Responses.NotAudio
+	case errors.Is(err, errFileTooLarge):
+		return p.config.FilesCommand.Responses.TooLarge
+	case errors.Is(err, errLibraryFull):
+		return p.config.FilesCommand.Responses.LibraryFull
+	case errors.Is(err, errNoGlobal):
+		return p.config.FilesCommand.Responses.NoGlobalLibrary
+	default:
+		logger.Error("failed to change library", slog.String("error", err.Error()))
+		return p.config.GlobalResponses.GenericError
+	}
+}
+
+func (p *Plugin) exportHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	entries := queueFileEntries(spotSession.player.List(true))
+	if len(entries) == 0 {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
+	jsonBytes, err := encodeQueueJSON(entries)
+	if err != nil {
+		logger.Error("failed to encode queue", slog.String("error", err.Error()))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	filename := "queue-" + time.Now().Format("20060102-150405")
+	response := &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("%s Exported %d songs.", p.config.ExportCommand.Responses.ExportSuccess, len(entries)),
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Files: []*discordgo.File{
+				{
+					Name:        filename + ".m3u8",
+					ContentType: "audio/x-mpegurl",
+					Reader:      bytes.NewReader(encodeM3U8(entries)),
+				},
+				{
+					Name:        filename + ".json",
+					ContentType: "application/json",
+					Reader:      bytes.NewReader(jsonBytes),
+				},
+			},
+		},
+	}
+
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Response(response).
+		SendWithLog(logger)
+
+	logger.Debug("user exported queue", slog.Int("tracks", len(entries)))
+}
+
+func (p *Plugin) importHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
+
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	if !spotSession.session.LoggedIn() {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotLoggedIn).
+			SendWithLog(logger)
+		return
+	}
+
+	importOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "import")
+	if importOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify import [...]"),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	var attachment *discordgo.MessageAttachment
+	if fileOption := utils.GetCommandOption(*importOption, "import", "file"); fileOption != nil {
+		if resolved := i.ApplicationCommandData().Resolved; resolved != nil {
+			attachmentId, _ := fileOption.Value.(string)
+			attachment = resolved.Attachments[attachmentId]
+		}
+	}
+
+	if attachment == nil {
+		logger.Error("required field not set", slog.String("field", "file"))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	if attachment.Size > maxQueueFileSize {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.ImportCommand.Responses.InvalidFile).
+			SendWithLog(logger)
+		return
+	}
+
+	// Looking up every track can take a while
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Deferred().
+		SendWithLog(logger)
+
+	resp, err := attachmentClient.Get(attachment.URL)
+	if err != nil {
+		logger.Error("failed to download file",
+			slog.String("error", err.Error()),
+			slog.String("url", attachment.URL),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			EditWithLog(logger)
+		return
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxQueueFileSize))
+	if err != nil {
+		logger.Error("failed to read file",
+			slog.String("error", err.Error()),
+			slog.String("url", attachment.URL),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			EditWithLog(logger)
+		return
+	}
+
+	lines, err := decodeQueueFile(attachment.Filename, b)
+	if err != nil || len(lines) == 0 {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message("Playlist too small for a quiz game.").
+			Message(p.config.ImportCommand.Responses.InvalidFile).
 			EditWithLog(logger)
 		return
 	}
 
-	ctx, cancelFunc := context.WithCancel(context.Background())
-	quizGame := &quiz{
-		playlist:          trackIds,
-		questionNumber:    1,
-		previousQuestions: threadsafe.NewMap[int, bool](),
+	// Lines are only resolved as they're queued, so nothing past the point the queue fills up is looked up
+	var failed []queueFileLine
+	queued, note := p.enqueuePlayables(spotSession, i, len(lines), func(index int) (apollo.Playable, bool) {
+		line := lines[index]
+		trackId, ok := line.entry.queueId()
+		if !ok {
+			failed = append(failed, line)
+			return nil, false
+		}
 
-		questions: questions,
-		scoreboard: threadsafe.NewMap[string, struct {
-			score     int
-			totalTime float64
-		}](),
-		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
-		cancelFunc: cancelFunc,
+		t, err := p.resolveTrackId(spotSession, i, trackId, discordFrequency)
+		if err != nil {
+			logger.Debug("failed to resolve imported track",
+				slog.String("error", err.Error()),
+				slog.Int("line", line.line),
+				slog.String("text", line.text),
+			)
+			failed = append(failed, line)
+			return nil, false
+		}
+
+		return t, true
+	}, -1, logger)
+
+	logger.Debug("user imported queue",
+		slog.String("file", attachment.Filename),
+		slog.Int("queued", queued),
+		slog.Int("failed", len(failed)),
+	)
+
+	message := fmt.Sprintf("%s %d songs added to queue.", p.config.ImportCommand.Responses.ImportSuccess, queued)
+	if note != "" {
+		message += "\n" + note
 	}
 
-	spotSession.quizGame = quizGame
+	if len(failed) > 0 {
+		message += "\n" + p.config.ImportCommand.Responses.FailedLines + "\n```\n"
+		for index, line := range failed {
+			failure := fmt.Sprintf("%d: %s\n", line.line, line.text)
 
-	userId := utils.GetInteractionUserId(i.Interaction)
-	quizGame.startMessage = fmt.Sprintf("<@%s> started a spotify quiz game! Click the button to join.\n", userId)
-	quizGame.startMessage += fmt.Sprintf("Category: `%s`", results[0].Name())
-	buttonBuilder := utils.Button().Id("spotify_quiz_join").Label("Join game")
-	components := utils.ActionsRow().Button(buttonBuilder.Build()).Build()
+			// Cut off slightly early before the message limit, just so we never risk not being able to send
+			if len(message)+len(failure)+25 >= 1995 {
+				message += fmt.Sprintf("...(+ %d more)\n", len(failed)-index)
+				break
+			}
+
+			message += failure
+		}
+		message += "```"
+	}
 
 	utils.InteractionResponse(discordSession, i.Interaction).
-		Components(components).
-		Message(quizGame.startMessage).
+		Ephemeral().
+		Message(message).
 		EditWithLog(logger)
+}
 
-	quizGame.startInteraction = i.Interaction
+func (p *Plugin) guildPlaylistHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+	logger := p.logger.With(
+		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
+		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
+	)
 
-	go func() {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(15 * time.Second):
-			break
-		}
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Deferred().
+		SendWithLog(logger)
 
-		// Close out the join option
-		message := fmt.Sprintf("%s\n```", spotSession.quizGame.startMessage)
-		for _, user := range spotSession.quizGame.scoreboard.Keys() {
-			message += fmt.Sprintf("%s joined.\n", user)
-		}
-		message += "```"
-		components = utils.ActionsRow().Button(buttonBuilder.Enabled(false).Build()).Build()
+	groupOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "playlist")
+	if groupOption == nil || len(groupOption.Options) == 0 {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify playlist [...]"),
+		)
 		utils.InteractionResponse(discordSession, i.Interaction).
-			Message(message).
-			Components(components).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
 			EditWithLog(logger)
+		return
+	}
+	subOption := groupOption.Options[0]
 
-		if len(quizGame.scoreboard.Data) == 0 {
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Message("No one joined :disappointed:").
-				FollowUpCreateWithLog(logger)
-			spotSession.quizGame = nil
-			return
+	var name string
+	if nameOption := utils.GetCommandOption(*subOption, subOption.Name, "name"); nameOption != nil {
+		name = strings.TrimSpace(nameOption.StringValue())
+	}
+
+	if name == "" && subOption.Name != "list" {
+		logger.Error("required field not set", slog.String("field", "name"))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			EditWithLog(logger)
+		return
+	}
+
+	var message string
+	switch subOption.Name {
+	case "save":
+		message = p.saveGuildPlaylist(i, name, logger)
+	case "load":
+		p.loadGuildPlaylist(discordSession, i, name, logger)
+		return
+	case "list":
+		message = p.listGuildPlaylists(i, logger)
+	case "delete":
+		message = p.deleteGuildPlaylist(i, name, logger)
+	case "add":
+		queryOption := utils.GetCommandOption(*subOption, "add", "query")
+		if queryOption == nil {
+			logger.Error("required field not set", slog.String("field", "query"))
+			message = p.config.GlobalResponses.GenericError
+			break
 		}
 
-		for index := 0; index < quizGame.questions; index++ {
-			if spotSession.quizGame == nil {
-				return
-			} else if len(quizGame.playlist) < 5 {
-				break
-			}
+		message = p.addToGuildPlaylist(i, name, strings.TrimSpace(queryOption.StringValue()), logger)
+	case "remove":
+		positionOption := utils.GetCommandOption(*subOption, "remove", "position")
+		if positionOption == nil {
+			logger.Error("required field not set", slog.String("field", "position"))
+			message = p.config.GlobalResponses.GenericError
+			break
+		}
 
-			quizGame.questionAnswer = quizGame.rng.Intn(5)
-			tracks := quizGame.getRandomTracks(spotSession.session, 5)
-			quizGame.questionAnswerTrack = tracks[quizGame.questionAnswer]
+		message = p.removeFromGuildPlaylist(i, name, int(positionOption.IntValue()), logger)
+	default:
+		logger.Error("interaction received unknown playlist subcommand", slog.String("subcommand", subOption.Name))
+		message = p.config.GlobalResponses.GenericError
+	}
 
-			trackIndex := slices.Index(quizGame.playlist, quizGame.questionAnswerTrack.Id())
-			if trackIndex != -1 {
-				quizGame.playlist = slices.Delete(quizGame.playlist, trackIndex, trackIndex+1)
-			}
+	utils.InteractionResponse(discordSession, i.Interaction).
+		Ephemeral().
+		Message(message).
+		EditWithLog(logger)
+}
 
-			quizGame.questionResponseTimes = threadsafe.NewMap[string, float64]()
-			for _, username := range quizGame.scoreboard.Keys() {
-				quizGame.questionResponseTimes.Set(username, 16.0)
-			}
+// playlistErrorMessage returns the response for an error from updating a guild's saved playlists.
+func (p *Plugin) playlistErrorMessage(err error, logger *slog.Logger) string {
+	switch {
+	case errors.Is(err, errPlaylistNotFound):
+		return p.config.GuildPlaylistCommand.Responses.NotFound
+	case errors.Is(err, errPlaylistExists):
+		return p.config.GuildPlaylistCommand.Responses.AlreadyExists
+	case errors.Is(err, errPlaylistDenied):
+		return p.config.GuildPlaylistCommand.Responses.NotAllowed
+	case errors.Is(err, errPlaylistPosition):
+		return p.config.GuildPlaylistCommand.Responses.InvalidPosition
+	default:
+		logger.Error("failed to update saved playlists", slog.String("error", err.Error()))
+		return p.config.GlobalResponses.GenericError
+	}
+}
 
-			t := track{
-				Track: tracks[quizGame.questionAnswer],
-				metadata: map[string]string{
-					"requesterId":   "george",
-					"requesterName": "george",
-					"frequency":     fmt.Sprintf("%d", discordFrequency),
-				},
-			}
-			spotSession.player.Enqueue(&t)
-			spotSession.player.Play()
-			quizGame.questionStartTime = time.Now()
-			questionMessage, err := utils.InteractionResponse(discordSession, i.Interaction).
-				Response(quizGame.generateQuestion(tracks)).
-				FollowUpCreate()
-			if err != nil {
-				logger.Error("failed to create followup", slog.String("error", err.Error()))
-			}
+// canEditPlaylist reports whether userId is allowed to change playlist. Only its creator and admins are.
+func (p *Plugin) canEditPlaylist(playlist savedPlaylist, userId string) bool {
+	return playlist.CreatorId == userId || slices.Contains(p.config.AdminIds, userId)
+}
 
-			go func() {
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(15 * time.Second):
-					break
-				}
+// saveGuildPlaylist saves whatever is playing, along with everything after it, as a new playlist called name.
+func (p *Plugin) saveGuildPlaylist(i *discordgo.InteractionCreate, name string, logger *slog.Logger) string {
+	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+	if !ok {
+		return p.config.GlobalResponses.NotInVoice
+	}
 
-				spotSession.player.Next()
+	var queue []apollo.Playable
+	if np, ok := spotSession.player.NowPlaying(); ok {
+		queue = append(queue, np)
+	}
+	queue = append(queue, spotSession.player.List(false)...)
 
-				utils.InteractionResponse(discordSession, i.Interaction).
-					Components().
-					FollowUpEditWithLog(questionMessage.ID, logger)
+	var entries []playlistEntry
+	for _, playable := range queue {
+		if entry, ok := playlistEntryFor(playable); ok {
+			entries = append(entries, entry)
+		}
+	}
 
-				utils.InteractionResponse(discordSession, i.Interaction).
-					Message(quizGame.generateQuestionWinner()).
-					FollowUpCreateWithLog(logger)
-			}()
+	if len(entries) == 0 {
+		return p.config.GlobalResponses.EmptyQueue
+	}
 
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(18 * time.Second):
-				break
-			}
+	err := updatePlaylists(i.Interaction.GuildID, func(playlists map[string]savedPlaylist) error {
+		if _, ok := playlists[playlistKey(name)]; ok {
+			return errPlaylistExists
+		}
 
-			quizGame.questionNumber++
+		playlists[playlistKey(name)] = savedPlaylist{
+			Name:        name,
+			CreatorId:   utils.GetInteractionUserId(i.Interaction),
+			CreatorName: utils.GetInteractionUserName(i.Interaction),
+			Tracks:      entries,
 		}
 
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Message(quizGame.generateGameWinner()).
-			FollowUpCreateWithLog(logger)
+		return nil
+	})
+	if err != nil {
+		return p.playlistErrorMessage(err, logger)
+	}
 
-		spotSession.quizGame = nil
-	}()
-}
+	logger.Debug("user saved playlist", slog.String("name", name), slog.Int("tracks", len(entries)))
 
-func (p *Plugin) quizMessageHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
-	logger := p.logger.With(
-		slog.Any("message_component", utils.MessageComponentInterface(i.MessageComponentData())),
-		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
-	)
+	return fmt.Sprintf("%s Saved %d songs to `%s`.", p.config.GuildPlaylistCommand.Responses.SaveSuccess, len(entries), name)
+}
 
+// loadGuildPlaylist asks whether the playlist called name should be queued. From there it's handled by
+// playlistMessageHandler the same way as a spotify playlist.
+func (p *Plugin) loadGuildPlaylist(discordSession *discordgo.Session, i *discordgo.InteractionCreate, name string, logger *slog.Logger) {
 	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
-	if !ok || spotSession.quizGame == nil {
+	if !ok {
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message("Game no longer exists.").SendWithLog(logger)
+			Message(p.config.GlobalResponses.NotInVoice).
+			EditWithLog(logger)
+		return
+	}
+
+	if !spotSession.session.LoggedIn() {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotLoggedIn).
+			EditWithLog(logger)
+		return
+	}
+
+	playlists, err := loadPlaylists(i.Interaction.GuildID)
+	if err != nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.playlistErrorMessage(err, logger)).
+			EditWithLog(logger)
+		return
+	}
+
+	playlist, ok := playlists[playlistKey(name)]
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GuildPlaylistCommand.Responses.NotFound).
+			EditWithLog(logger)
+		return
+	}
+
+	if len(playlist.Tracks) == 0 {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GuildPlaylistCommand.Responses.EmptyPlaylist).
+			EditWithLog(logger)
 		return
 	}
 
+	c := collection{
+		resourceType: spotify.PlaylistResourceType,
+		name:         playlist.Name,
+	}
+	for _, entry := range playlist.Tracks {
+		c.trackIds = append(c.trackIds, entry.queueId())
+	}
+
+	uid := utils.ShaSum(fmt.Sprintf("%s%s%d",
+		i.Interaction.GuildID,
+		utils.GetInteractionUserId(i.Interaction),
+		time.Now().UnixNano(),
+	))
+
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
-		Deferred().
-		SendWithLog(logger)
+		Components(collectionButtons(uid)).
+		Message(c.prompt()).
+		EditWithLog(logger)
 
-	messageData := i.MessageComponentData()
-	switch {
-	case strings.HasPrefix(messageData.CustomID, "spotify_quiz_join"):
-		username := utils.GetInteractionUserName(i.Interaction)
+	spotSession.playInteractions.Set(uid, playInteraction{
+		trackIds:   c.trackIds,
+		collection: &c,
+		frequency:  discordFrequency,
+	})
+	logger.Debug("play interaction created", slog.String("uid", uid))
+}
 
-		if _, ok = spotSession.quizGame.scoreboard.Get(username); ok {
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message("gl;hf").
-				EditWithLog(logger)
-			return
+// listGuildPlaylists lists every playlist saved by the guild.
+func (p *Plugin) listGuildPlaylists(i *discordgo.InteractionCreate, logger *slog.Logger) string {
+	playlists, err := loadPlaylists(i.Interaction.GuildID)
+	if err != nil {
+		return p.playlistErrorMessage(err, logger)
+	}
+
+	if len(playlists) == 0 {
+		return p.config.GuildPlaylistCommand.Responses.NoPlaylists
+	}
+
+	sorted := sortedPlaylists(playlists)
+	message := "```\n"
+	for index, playlist := range sorted {
+		line := fmt.Sprintf("%s - %d songs (@%s)\n", playlist.Name, len(playlist.Tracks), playlist.CreatorName)
+
+		// Cut off slightly early before the message limit, just so we never risk not being able to send
+		if len(message)+len(line)+25 >= 1995 {
+			message += fmt.Sprintf("...(+ %d more)\n", len(sorted)-index)
+			break
 		}
 
-		spotSession.quizGame.scoreboard.Set("@"+username, struct {
-			score     int
-			totalTime float64
-		}{score: 0, totalTime: 0.0})
+		message += line
+	}
+	message += "```"
+
+	return message
+}
+
+// deleteGuildPlaylist deletes the playlist called name.
+func (p *Plugin) deleteGuildPlaylist(i *discordgo.InteractionCreate, name string, logger *slog.Logger) string {
+	userId := utils.GetInteractionUserId(i.Interaction)
 
-		message := fmt.Sprintf("%s\n```", spotSession.quizGame.startMessage)
-		for _, user := range spotSession.quizGame.scoreboard.Keys() {
-			message += fmt.Sprintf("%s joined.\n", user)
+	err := updatePlaylists(i.Interaction.GuildID, func(playlists map[string]savedPlaylist) error {
+		playlist, ok := playlists[playlistKey(name)]
+		if !ok {
+			return errPlaylistNotFound
+		} else if !p.canEditPlaylist(playlist, userId) {
+			return errPlaylistDenied
 		}
-		message += "```"
 
-		buttonBuilder := utils.Button().Id("spotify_quiz_join").Label("Join game")
-		components := utils.ActionsRow().Button(buttonBuilder.Build()).Build()
-		utils.InteractionResponse(discordSession, spotSession.quizGame.startInteraction).
-			Components(components).
-			Message(message).EditWithLog(logger)
+		delete(playlists, playlistKey(name))
 
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message("gl;hf").
-			EditWithLog(logger)
+		return nil
+	})
+	if err != nil {
+		return p.playlistErrorMessage(err, logger)
+	}
 
-	case strings.HasPrefix(messageData.CustomID, "spotify_quiz_answer"):
-		username := fmt.Sprintf("@%s", utils.GetInteractionUserName(i.Interaction))
+	logger.Debug("user deleted playlist", slog.String("name", name))
 
-		if _, ok = spotSession.quizGame.scoreboard.Get(username); !ok {
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message("You aren't a part of this round.").
-				EditWithLog(logger)
-			return
+	return fmt.Sprintf("%s Deleted `%s`.", p.config.GuildPlaylistCommand.Responses.DeleteSuccess, name)
+}
+
+// addToGuildPlaylist adds the local file or spotify track matching query to the end of the playlist called name. The
+// playlist is created if it doesn't exist yet.
+func (p *Plugin) addToGuildPlaylist(i *discordgo.InteractionCreate, name string, query string, logger *slog.Logger) string {
+	userId := utils.GetInteractionUserId(i.Interaction)
+	username := utils.GetInteractionUserName(i.Interaction)
+
+	var entry playlistEntry
+	if file, err := p.getLocalFile(i.GuildID, query, userId, username); err == nil {
+		entry, _ = playlistEntryFor(&file)
+	} else {
+		spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
+		if !ok {
+			return p.config.GlobalResponses.NotInVoice
 		}
 
-		if responseTime, _ := spotSession.quizGame.questionResponseTimes.Get(username); responseTime != 16.0 {
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message("You already selected an answer for this round.").
-				EditWithLog(logger)
-			return
+		if !spotSession.session.LoggedIn() {
+			return p.config.GlobalResponses.NotLoggedIn
 		}
 
-		idSplit := strings.Split(messageData.CustomID, "_")
-		if len(idSplit) != 4 {
-			logger.Error("message component data interaction response had an unknown custom ID",
-				slog.String("custom_id", messageData.CustomID),
-			)
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.GlobalResponses.GenericError).
-				EditWithLog(logger)
-			return
+		trackIds, err := spotSession.session.Search(query).Limit(1).TrackIds()
+		if err != nil {
+			logger.Error("spotify search failed", slog.String("error", err.Error()))
+			return p.config.GlobalResponses.GenericError
 		}
 
-		answer, err := strconv.Atoi(idSplit[3])
+		if len(trackIds) == 0 {
+			return p.config.PlayCommand.Responses.NoTracksFound
+		}
+
+		t, err := spotSession.session.GetTrackById(trackIds[0])
 		if err != nil {
-			logger.Error("failed to convert id to int",
+			logger.Error("failed to retrieve track by id",
 				slog.String("error", err.Error()),
-				slog.String("id", idSplit[3]),
+				slog.String("id", trackIds[0]),
 			)
+			return p.config.GlobalResponses.GenericError
+		}
 
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(p.config.GlobalResponses.GenericError).
-				EditWithLog(logger)
-			return
+		if p.isBanned(i.Interaction.GuildID, t) {
+			return p.config.PlayCommand.Responses.BannedTrack
+		}
 
+		entry, _ = playlistEntryFor(t)
+	}
+
+	err := updatePlaylists(i.Interaction.GuildID, func(playlists map[string]savedPlaylist) error {
+		playlist, ok := playlists[playlistKey(name)]
+		if !ok {
+			playlist = savedPlaylist{Name: name, CreatorId: userId, CreatorName: username}
+		} else if !p.canEditPlaylist(playlist, userId) {
+			return errPlaylistDenied
 		}
 
-		timeElapsed := time.Since(spotSession.quizGame.questionStartTime).Round(time.Millisecond).Seconds()
-		if answer-1 == spotSession.quizGame.questionAnswer {
-			spotSession.quizGame.questionResponseTimes.Set(username, timeElapsed)
+		playlist.Tracks = append(playlist.Tracks, entry)
+		playlists[playlistKey(name)] = playlist
+		name = playlist.Name
 
-			score, _ := spotSession.quizGame.scoreboard.Get(username)
-			score.totalTime += timeElapsed
-			spotSession.quizGame.scoreboard.Set(username, score)
-		} else {
-			spotSession.quizGame.questionResponseTimes.Set(username, 15.0)
+		return nil
+	})
+	if err != nil {
+		return p.playlistErrorMessage(err, logger)
+	}
 
-			score, _ := spotSession.quizGame.scoreboard.Get(username)
-			score.totalTime += 15.0
-			spotSession.quizGame.scoreboard.Set(username, score)
+	logger.Debug("user added to playlist", slog.String("name", name), slog.Any("entry", entry))
+
+	return fmt.Sprintf("%s Added %s by %s to `%s`.",
+		p.config.GuildPlaylistCommand.Responses.AddSuccess, entry.Name, entry.Artist, name)
+}
+
+// removeFromGuildPlaylist removes the song at position (starting from 1) from the playlist called name.
+func (p *Plugin) removeFromGuildPlaylist(i *discordgo.InteractionCreate, name string, position int, logger *slog.Logger) string {
+	userId := utils.GetInteractionUserId(i.Interaction)
+
+	var removed playlistEntry
+	err := updatePlaylists(i.Interaction.GuildID, func(playlists map[string]savedPlaylist) error {
+		playlist, ok := playlists[playlistKey(name)]
+		if !ok {
+			return errPlaylistNotFound
+		} else if !p.canEditPlaylist(playlist, userId) {
+			return errPlaylistDenied
+		} else if position < 1 || position > len(playlist.Tracks) {
+			return errPlaylistPosition
 		}
 
-		message := fmt.Sprintf("You answered in: %.3fs :stopwatch:", timeElapsed)
-		utils.InteractionResponse(discordSession, i.Interaction).
-			Ephemeral().
-			Message(message).
-			EditWithLog(logger)
+		removed = playlist.Tracks[position-1]
+		playlist.Tracks = slices.Delete(playlist.Tracks, position-1, position)
+		playlists[playlistKey(name)] = playlist
+		name = playlist.Name
+
+		return nil
+	})
+	if err != nil {
+		return p.playlistErrorMessage(err, logger)
 	}
+
+	logger.Debug("user removed from playlist", slog.String("name", name), slog.Any("entry", removed))
+
+	return fmt.Sprintf("%s Removed %s by %s from `%s`.",
+		p.config.GuildPlaylistCommand.Responses.RemoveSuccess, removed.Name, removed.Artist, name)
 }
 
-func (p *Plugin) listifyHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+func (p *Plugin) autoplayHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	logger := p.logger.With(
 		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
 		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
@@ -1487,47 +3302,49 @@ func (p *Plugin) listifyHandler(discordSession *discordgo.Session, i *discordgo.
 		return
 	}
 
-	queue := spotSession.player.List(true)
-	if len(queue) == 0 {
+	autoplayOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "autoplay")
+	if autoplayOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify autoplay [...]"),
+		)
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
-			Message(p.config.GlobalResponses.EmptyQueue).
+			Message(p.config.GlobalResponses.GenericError).
 			SendWithLog(logger)
 		return
 	}
 
-	// Send a deferred message that way we can follow up repeatedly
-	utils.InteractionResponse(discordSession, i.Interaction).
-		Ephemeral().
-		Deferred().
-		SendWithLog(logger)
-
-	message := "```\n"
-	for index := range queue {
-		line := fmt.Sprintf("%d) %s - %s (@%s)\n", index+1, queue[index].Name(), queue[index].Artist(), queue[index].Metadata()["requesterName"])
-
-		// Cut off slightly early before the line limit, just so we never risk not being able to send
-		if len(message)+len(line)+3 >= 1995 {
-			message += "```"
-			utils.InteractionResponse(discordSession, i.Interaction).
-				Ephemeral().
-				Message(message).
-				FollowUpCreateWithLog(logger)
+	enabledOption := utils.GetCommandOption(*autoplayOption, "autoplay", "enabled")
+	if enabledOption == nil {
+		logger.Error("required field not set", slog.String("field", "enabled"))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
 
-			message = "```\n"
-		}
+	spotSession.autoplay = enabledOption.BoolValue()
+	logger.Debug("user changed autoplay", slog.Bool("enabled", spotSession.autoplay))
 
-		message += line
+	message := p.config.AutoplayCommand.Responses.AutoplayOff
+	if spotSession.autoplay {
+		message = p.config.AutoplayCommand.Responses.AutoplayOn
 	}
-	message += "```"
 
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
 		Message(message).
-		FollowUpCreateWithLog(logger)
+		SendWithLog(logger)
+
+	// If the queue already ran out, there's nothing left to trigger autoplay so it has to be kicked off here. The fill
+	// starts playback itself once it finds something.
+	if spotSession.autoplay && spotSession.player.State() == apollo.IdleState && len(spotSession.player.List(true)) > 0 {
+		spotSession.fillAutoplay()
+	}
 }
 
-func (p *Plugin) clearHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+func (p *Plugin) seekHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	logger := p.logger.With(
 		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
 		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
@@ -1535,6 +3352,40 @@ func (p *Plugin) clearHandler(discordSession *discordgo.Session, i *discordgo.In
 
 	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
 	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	t, ok := spotSession.player.NowPlaying()
+	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.EmptyQueue).
+			SendWithLog(logger)
+		return
+	}
+
+	userId := utils.GetInteractionUserId(i.Interaction)
+	if strings.ToLower(p.config.RestrictSkips) == "true" && !spotSession.checkPermissions(t, userId) {
+		logger.Debug("user tried to seek a track they don't own",
+			slog.String("author_id", t.Metadata()["requesterId"]),
+			slog.String("track", t.Name()),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.PermissionDenied).
+			SendWithLog(logger)
+		return
+	}
+
+	seekOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "seek")
+	if seekOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify seek [...]"),
+		)
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
 			Message(p.config.GlobalResponses.GenericError).
@@ -1542,15 +3393,66 @@ func (p *Plugin) clearHandler(discordSession *discordgo.Session, i *discordgo.In
 		return
 	}
 
-	spotSession.player.Empty()
+	positionOption := utils.GetCommandOption(*seekOption, "seek", "position")
+	if positionOption == nil {
+		logger.Error("required field not set", slog.String("field", "position"))
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	position, relative, err := parseSeekPosition(positionOption.StringValue())
+	if err != nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.SeekCommand.Responses.InvalidPosition).
+			SendWithLog(logger)
+		return
+	}
+
+	if relative {
+		position += spotSession.codec.Position()
+	}
+	if position < 0 {
+		position = 0
+	}
+
+	if position >= t.Duration() {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.SeekCommand.Responses.InvalidPosition).
+			SendWithLog(logger)
+		return
+	}
+
+	if err = spotSession.codec.Seek(position); err != nil {
+		logger.Error("failed to seek track",
+			slog.String("error", err.Error()),
+			slog.String("track", t.Name()),
+		)
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.GenericError).
+			SendWithLog(logger)
+		return
+	}
+
+	logger.Debug("user seeked track",
+		slog.String("track", t.Name()),
+		slog.String("position", position.String()),
+	)
 
+	message := fmt.Sprintf("%s [%s/%s]", p.config.SeekCommand.Responses.SeekSuccess,
+		position.Round(time.Second).String(), t.Duration().Round(time.Second).String())
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
-		Message(p.config.GlobalResponses.GenericSuccess).
+		Message(message).
 		SendWithLog(logger)
 }
 
-func (p *Plugin) shuffleHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
+func (p *Plugin) volumeHandler(discordSession *discordgo.Session, i *discordgo.InteractionCreate) {
 	logger := p.logger.With(
 		slog.String("command", utils.CommandDataString(i.ApplicationCommandData())),
 		slog.Any("user", utils.GetInteractionUser(i.Interaction)),
@@ -1558,6 +3460,18 @@ func (p *Plugin) shuffleHandler(discordSession *discordgo.Session, i *discordgo.
 
 	spotSession, ok := p.sessions.Get(i.Interaction.GuildID)
 	if !ok {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(p.config.GlobalResponses.NotInVoice).
+			SendWithLog(logger)
+		return
+	}
+
+	volumeOption := utils.GetCommandOption(i.ApplicationCommandData(), "spotify", "volume")
+	if volumeOption == nil {
+		logger.Error("unexpected command data found for command",
+			slog.String("expected", "spotify volume [...]"),
+		)
 		utils.InteractionResponse(discordSession, i.Interaction).
 			Ephemeral().
 			Message(p.config.GlobalResponses.GenericError).
@@ -1565,16 +3479,28 @@ func (p *Plugin) shuffleHandler(discordSession *discordgo.Session, i *discordgo.
 		return
 	}
 
-	spotSession.player.Shuffle(false)
+	// No percent just reports the current volume
+	percentOption := utils.GetCommandOption(*volumeOption, "volume", "percent")
+	if percentOption == nil {
+		utils.InteractionResponse(discordSession, i.Interaction).
+			Ephemeral().
+			Message(fmt.Sprintf("%s %d%%.", p.config.VolumeCommand.Responses.CurrentVolume, spotSession.volume)).
+			SendWithLog(logger)
+		return
+	}
+
+	spotSession.setVolume(int(percentOption.IntValue()))
+	logger.Debug("user changed volume", slog.Int("volume", spotSession.volume))
 
 	utils.InteractionResponse(discordSession, i.Interaction).
 		Ephemeral().
-		Message(p.config.GlobalResponses.GenericSuccess).
+		Message(fmt.Sprintf("%s %d%%", p.config.VolumeCommand.Responses.VolumeSuccess, spotSession.volume)).
 		SendWithLog(logger)
 }
 
 func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *discordgo.MessageCreate) {
-	if message == nil || message.Author == nil {
+	// Files are kept per guild, so there's nowhere to put anything sent in a DM
+	if message == nil || message.Author == nil || message.GuildID == "" {
 		return
 	}
 
@@ -1615,37 +3541,19 @@ func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *d
 					break
 				}
 
+				failed := false
+				var outcomes []string
 				for _, attachment := range message.Attachments {
-					path := filepath.Join("downloads", attachment.Filename)
-					out, err := os.Create(path)
-					if err != nil {
-						p.logger.Error("failed to create file",
-							slog.String("error", err.Error()),
-							slog.String("path", path),
-						)
-						return
-					}
-					defer out.Close()
-
-					resp, err := http.Get(attachment.URL)
-					if err != nil {
-						p.logger.Error("failed to download file",
-							slog.String("error", err.Error()),
-							slog.String("url", attachment.URL),
-						)
-						return
-					}
-					defer resp.Body.Close()
-
-					if _, err = io.Copy(out, resp.Body); err != nil {
-						p.logger.Error("failed to copy file",
-							slog.String("error", err.Error()),
-							slog.String("path", path),
-						)
-					}
+					entry, err := p.downloadToLibrary(p.guildLibrary(message.GuildID), attachment)
+					failed = failed || err != nil
+					outcomes = append(outcomes, p.uploadOutcome(attachment, entry, err, p.logger))
 				}
 
-				_, _ = discordSession.ChannelMessageSend(message.ChannelID, "omnomnomnom delicioso :yum:")
+				m := strings.Join(outcomes, "\n")
+				if !failed {
+					m = "omnomnomnom delicioso :yum:\n" + m
+				}
+				_, _ = discordSession.ChannelMessageSend(message.ChannelID, m)
 
 				return
 			}
@@ -1656,7 +3564,7 @@ func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *d
 	for _, word := range listTriggerWords {
 		if slices.Contains(contentWords, "george") && slices.Contains(contentWords, word) {
 			//if strings.Contains(squishedContent, "george") && strings.Contains(squishedContent, word) {
-			entries, _ := os.ReadDir("downloads/")
+			entries := p.listLocalFiles(message.GuildID, "")
 			if len(entries) == 0 {
 				_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I don't have anything :pleading_face:")
 				return
@@ -1665,7 +3573,7 @@ func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *d
 			_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I have:")
 			m := "```\n"
 			for _, entry := range entries {
-				m += entry.Name() + "\n"
+				m += entry.ref() + "\n"
 			}
 			m += "```"
 			_, _ = discordSession.ChannelMessageSend(message.ChannelID, m)
@@ -1676,6 +3584,10 @@ func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *d
 
 	// Rename check
 	if strings.HasPrefix(lowercaseContent, "george rename") || strings.HasPrefix(lowercaseContent, "george mv") {
+		if !slices.Contains(p.config.AdminIds, message.Author.ID) {
+			return
+		}
+
 		splitContent := strings.Split(message.Content, " ")
 		if len(splitContent) != 4 {
 			p.logger.Error("rename message was not formatted correctly", slog.String("content", message.Content))
@@ -1685,37 +3597,28 @@ func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *d
 		filename := splitContent[2]
 		newName := splitContent[3]
 
-		entries, err := os.ReadDir("downloads/")
-		if err != nil {
-			p.logger.Error("failed to read downloads directory", slog.String("error", err.Error()))
+		if _, err := p.renameLibraryFile(message.GuildID, filename, newName); errors.Is(err, errFileNotFound) {
+			_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I don't think I have that file.")
+			return
+		} else if err != nil {
+			p.logger.Error("failed to rename file",
+				slog.String("error", err.Error()),
+				slog.String("from", filename),
+				slog.String("to", newName),
+			)
+			_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I done goofed.")
 			return
-		}
-		for _, entry := range entries {
-			if entry.Name() == filename {
-				from := strings.TrimSpace(filepath.Join("downloads/", filename))
-				to := strings.TrimSpace(filepath.Join("downloads/", newName))
-
-				if err = os.Rename(from, to); err != nil {
-					p.logger.Error("failed to rename file",
-						slog.String("error", err.Error()),
-						slog.String("from", from),
-						slog.String("to", to),
-					)
-					_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I done goofed.")
-					return
-				}
-
-				_, _ = discordSession.ChannelMessageSend(message.ChannelID, "Done :sunglasses:")
-
-				return
-			}
 		}
 
-		_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I don't think I have that file.")
+		_, _ = discordSession.ChannelMessageSend(message.ChannelID, "Done :sunglasses:")
 	}
 
 	// Remove check
 	if strings.HasPrefix(lowercaseContent, "george remove") || strings.HasPrefix(lowercaseContent, "george rm") {
+		if !slices.Contains(p.config.AdminIds, message.Author.ID) {
+			return
+		}
+
 		splitContent := strings.Split(message.Content, " ")
 		if len(splitContent) != 3 {
 			p.logger.Error("remove message was not formatted correctly", slog.String("content", message.Content))
@@ -1724,54 +3627,81 @@ func (p *Plugin) fileUploadHandler(discordSession *discordgo.Session, message *d
 
 		filename := splitContent[2]
 
-		entries, err := os.ReadDir("downloads/")
-		if err != nil {
-			p.logger.Error("failed to read downloads directory", slog.String("error", err.Error()))
+		if _, err := p.removeLibraryFile(message.GuildID, filename); errors.Is(err, errFileNotFound) {
+			_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I don't think I have that file.")
+			return
+		} else if err != nil {
+			p.logger.Error("failed to remove file",
+				slog.String("error", err.Error()),
+				slog.String("path", filename),
+			)
+			_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I done goofed.")
 			return
 		}
-		for _, entry := range entries {
-			if entry.Name() == filename {
-				path := strings.TrimSpace(filepath.Join("downloads/", filename))
-
-				if err = os.Remove(path); err != nil {
-					p.logger.Error("failed to remove file",
-						slog.String("error", err.Error()),
-						slog.String("path", path),
-					)
-					_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I done goofed.")
-					return
-				}
 
-				_, _ = discordSession.ChannelMessageSend(message.ChannelID, "Done :sunglasses:")
-
-				return
-			}
-		}
+		_, _ = discordSession.ChannelMessageSend(message.ChannelID, "Done :sunglasses:")
+	}
+}
 
-		_, _ = discordSession.ChannelMessageSend(message.ChannelID, "I don't think I have that file.")
+// getLocalFile opens the local file called name as the given guild sees it, see findLocalFile.
+func (p *Plugin) getLocalFile(guildId string, name string, userId string, username string) (localFile, error) {
+	entry, ok := p.findLocalFile(guildId, name)
+	if !ok {
+		return localFile{}, fmt.Errorf("no local file found")
 	}
+
+	return entry.open(userId, username)
 }
 
-func (p *Plugin) getLocalFile(name string, userId string, username string) (apollo.LocalFile, error) {
-	var localFile apollo.LocalFile
+// parseSeekPosition parses a timestamp such as "1:23" or "1:02:03", a plain number of seconds, or a go duration such
+// as "1m30s". A leading + or - marks the position as relative to the current position.
+func parseSeekPosition(s string) (time.Duration, bool, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false, fmt.Errorf("empty position")
+	}
 
-	entries, err := os.ReadDir("downloads/")
-	if err != nil {
-		return localFile, err
+	relative := false
+	sign := time.Duration(1)
+	switch s[0] {
+	case '-':
+		sign = -1
+		fallthrough
+	case '+':
+		relative = true
+		s = s[1:]
 	}
 
-	for _, entry := range entries {
-		if entry.Name() == name && !entry.IsDir() {
-			localFile, err = apollo.NewLocalFile(filepath.Join("downloads", entry.Name()))
-			localFile.Mdata = map[string]string{
-				"requesterId":   userId,
-				"requesterName": username,
-				"frequency":     fmt.Sprintf("%d", discordFrequency),
-			}
+	var position time.Duration
+	switch {
+	case strings.Contains(s, ":"):
+		fields := strings.Split(s, ":")
+		if len(fields) > 3 {
+			return 0, false, fmt.Errorf("invalid timestamp %q", s)
+		}
 
-			return localFile, err
+		for _, field := range fields {
+			v, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return 0, false, err
+			}
+			position = position*60 + time.Duration(v)*time.Second
+		}
+	default:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			// ParseFloat happily accepts NaN and Inf, neither of which is a position
+			if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) > math.MaxInt64/float64(time.Second) {
+				return 0, false, fmt.Errorf("invalid position %q", s)
+			}
+			position = time.Duration(v * float64(time.Second))
+		} else if position, err = time.ParseDuration(s); err != nil {
+			return 0, false, err
 		}
 	}
 
-	return apollo.LocalFile{}, fmt.Errorf("no local file found")
+	if position < 0 {
+		return 0, false, fmt.Errorf("invalid position %q", s)
+	}
+
+	return sign * position, relative, nil
 }
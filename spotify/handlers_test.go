@@ -0,0 +1,56 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSeekPosition(t *testing.T) {
+	tests := []struct {
+		input        string
+		want         time.Duration
+		wantRelative bool
+		wantErr      bool
+	}{
+		{"90", 90 * time.Second, false, false},
+		{"1.5", 1500 * time.Millisecond, false, false},
+		{" 42 ", 42 * time.Second, false, false},
+		{"1:23", 83 * time.Second, false, false},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false, false},
+		{"0:00", 0, false, false},
+		{"1m30s", 90 * time.Second, false, false},
+		{"+10", 10 * time.Second, true, false},
+		{"-5", -5 * time.Second, true, false},
+		{"-1:00", -time.Minute, true, false},
+		{"+1m", time.Minute, true, false},
+		{"", 0, false, true},
+		{"+", 0, false, true},
+		{"--5", 0, false, true},
+		{"1:2:3:4", 0, false, true},
+		{"1:-2", 0, false, true},
+		{"a:b", 0, false, true},
+		{"abc", 0, false, true},
+		{"NaN", 0, false, true},
+		{"Inf", 0, false, true},
+		{"1e300", 0, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, relative, err := parseSeekPosition(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got %s, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want || relative != test.wantRelative {
+				t.Errorf("got %s (relative %t), want %s (relative %t)", got, relative, test.want, test.wantRelative)
+			}
+		})
+	}
+}
@@ -0,0 +1,527 @@
+package spotify
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/apollo"
+)
+
+// libraryDir is where local files are kept. Each guild has its own library in a directory named after it, alongside the
+// shared global library. Files can be organised into subdirectories.
+const libraryDir = "downloads"
+
+// globalLibraryName is the directory of the global library within libraryDir. Guild ids are always numeric, so it can't
+// clash with a guild's library.
+const globalLibraryName = "global"
+
+// globalFilePrefix marks the name of a file as being in the global library rather than the guild's own.
+const globalFilePrefix = "global:"
+
+// librarySearchLimit is how many local files are offered alongside spotify results when searching.
+const librarySearchLimit = 5
+
+// libraryEntry is an indexed local file.
+type libraryEntry struct {
+	// dir is the directory of the library e is in.
+	dir string
+	// global is set if e is in the global library.
+	global bool
+	// path is relative to dir and always uses forward slashes, which is also how users refer to the file.
+	path     string
+	title    string
+	artist   string
+	album    string
+	duration time.Duration
+	// artworkMime is the MIME type of the artwork embedded in the file, or "" if there isn't any.
+	artworkMime string
+
+	size    int64
+	modTime time.Time
+}
+
+// name returns the file name of e.
+func (e libraryEntry) name() string {
+	return filepath.Base(filepath.FromSlash(e.path))
+}
+
+// fullPath returns the path of e on disk.
+func (e libraryEntry) fullPath() string {
+	return filepath.Join(e.dir, filepath.FromSlash(e.path))
+}
+
+// ref returns how e is referred to by users, queues and playlists. Files in the global library are marked with
+// globalFilePrefix.
+func (e libraryEntry) ref() string {
+	if e.global {
+		return globalFilePrefix + e.path
+	}
+
+	return e.path
+}
+
+// open creates a localFile for e requested by the given user. Its name, artist, album and duration come from the
+// index where the file was tagged.
+func (e libraryEntry) open(userId string, username string) (localFile, error) {
+	file, err := apollo.NewLocalFile(e.fullPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return localFile{}, fmt.Errorf("local file %s no longer exists", e.path)
+		}
+		return localFile{}, err
+	}
+	file.Mdata = map[string]string{
+		"path":          e.fullPath(),
+		"requesterId":   userId,
+		"requesterName": username,
+		"frequency":     fmt.Sprintf("%d", discordFrequency),
+	}
+
+	return localFile{LocalFile: file, entry: e}, nil
+}
+
+// label describes e for users, preferring its tags over its path.
+func (e libraryEntry) label() string {
+	switch {
+	case e.title != "" && e.artist != "":
+		return fmt.Sprintf("%s - %s", e.title, e.artist)
+	case e.title != "":
+		return e.title
+	default:
+		return e.ref()
+	}
+}
+
+// displayTitle returns the title of e, falling back to its file name the same way apollo does.
+func (e libraryEntry) displayTitle() string {
+	if e.title != "" {
+		return e.title
+	}
+
+	return e.name()
+}
+
+// displayArtist returns the artist of e, falling back to "local" the same way apollo does.
+func (e libraryEntry) displayArtist() string {
+	if e.artist != "" {
+		return e.artist
+	}
+
+	return "local"
+}
+
+// resultOption returns e as an option for the search results select menu.
+func (e libraryEntry) resultOption() discordgo.SelectMenuOption {
+	return discordgo.SelectMenuOption{
+		Label:       truncate(e.displayTitle(), maxChoiceLength),
+		Value:       localFilePrefix + e.ref(),
+		Description: truncate(fmt.Sprintf("%s - %s (%s)", e.displayArtist(), e.duration.Round(time.Second), e.origin()), maxChoiceLength),
+	}
+}
+
+// origin describes which library e is in, for labelling it alongside spotify results.
+func (e libraryEntry) origin() string {
+	if e.global {
+		return "global"
+	}
+
+	return "local"
+}
+
+// matches reports whether every word in query appears somewhere in e's path or tags.
+func (e libraryEntry) matches(query string) bool {
+	haystack := strings.ToLower(strings.Join([]string{e.path, e.title, e.artist, e.album}, "\n"))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(haystack, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// library indexes every file under dir along with the tags embedded in it, so that local files can be found by title
+// or artist instead of only their exact file name.
+type library struct {
+	dir     string
+	global  bool
+	mu      sync.RWMutex
+	entries map[string]libraryEntry
+	logger  *slog.Logger
+}
+
+func newLibrary(dir string, global bool, logger *slog.Logger) *library {
+	return &library{
+		dir:     dir,
+		global:  global,
+		entries: make(map[string]libraryEntry),
+		logger:  logger.With(slog.String("library", dir)),
+	}
+}
+
+// relPath converts path (on disk) to how it's stored in the index. false is returned if path isn't inside the library.
+func (l *library) relPath(path string) (string, bool) {
+	rel, err := filepath.Rel(l.dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
+
+// scan walks the library and rebuilds the index. Files that haven't changed since they were last read keep their
+// existing entry.
+func (l *library) scan() {
+	entries := make(map[string]libraryEntry)
+
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			l.logger.Error("failed to read library path", slog.String("error", err.Error()), slog.String("path", path))
+			return nil
+		}
+
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+
+		rel, ok := l.relPath(path)
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		l.mu.RLock()
+		entry, ok := l.entries[rel]
+		l.mu.RUnlock()
+		if !ok || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+			entry = l.readEntry(rel, info)
+		}
+		entries[rel] = entry
+
+		return nil
+	})
+	if err != nil {
+		l.logger.Error("failed to scan library", slog.String("error", err.Error()))
+		return
+	}
+
+	l.mu.Lock()
+	l.entries = entries
+	l.mu.Unlock()
+
+	l.logger.Debug("library scanned", slog.Int("files", len(entries)))
+}
+
+// readEntry builds the entry for the file at rel, reading whatever tags it has. Files without tags are still indexed by
+// their path.
+func (l *library) readEntry(rel string, info fs.FileInfo) libraryEntry {
+	entry := libraryEntry{
+		dir:     l.dir,
+		global:  l.global,
+		path:    rel,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+
+	tags, _ := readTags(entry.fullPath())
+	entry.title = tags.title
+	entry.artist = tags.artist
+	entry.album = tags.album
+	entry.duration = tags.duration
+	entry.artworkMime = tags.artworkMime
+
+	return entry
+}
+
+// update (re)indexes the file at path, or drops it from the index if it no longer exists. This should be called
+// whenever a file is added, changed or removed.
+func (l *library) update(path string) {
+	rel, ok := l.relPath(path)
+	if !ok {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		l.mu.Lock()
+		delete(l.entries, rel)
+		l.mu.Unlock()
+		return
+	}
+
+	entry := l.readEntry(rel, info)
+
+	l.mu.Lock()
+	l.entries[rel] = entry
+	l.mu.Unlock()
+}
+
+// added indexes the file just written to path and returns its entry.
+func (l *library) added(path string) libraryEntry {
+	l.update(path)
+
+	rel, _ := l.relPath(path)
+	if entry, ok := l.find(rel); ok {
+		return entry
+	}
+
+	return libraryEntry{dir: l.dir, global: l.global, path: rel}
+}
+
+// find returns the entry for name, which is either the path of a file relative to the library or just its file name.
+// Paths take priority, and if several files share a name the first by path is returned.
+func (l *library) find(name string) (libraryEntry, bool) {
+	name = filepath.ToSlash(strings.TrimSpace(name))
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if entry, ok := l.entries[name]; ok {
+		return entry, true
+	}
+
+	var found *libraryEntry
+	for _, entry := range l.entries {
+		if entry.name() == name && (found == nil || entry.path < found.path) {
+			found = &entry
+		}
+	}
+
+	if found == nil {
+		return libraryEntry{}, false
+	}
+
+	return *found, true
+}
+
+// search returns the entries matching query. Entries whose title or artist matches are put before those that only
+// matched on their path or album, otherwise they're ordered by path.
+func (l *library) search(query string) []libraryEntry {
+	matched := l.list(query)
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return tagMatch(matched[i], query) && !tagMatch(matched[j], query)
+	})
+
+	return matched
+}
+
+// tagMatch reports whether the title or artist of e contains query.
+func tagMatch(e libraryEntry, query string) bool {
+	query = strings.ToLower(query)
+
+	return strings.Contains(strings.ToLower(e.title), query) || strings.Contains(strings.ToLower(e.artist), query)
+}
+
+// size returns the total size of every file in the library, in bytes.
+func (l *library) size() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var total int64
+	for _, entry := range l.entries {
+		total += entry.size
+	}
+
+	return total
+}
+
+// list returns every entry matching query ordered by path. An empty query matches everything.
+func (l *library) list(query string) []libraryEntry {
+	l.mu.RLock()
+	var matched []libraryEntry
+	for _, entry := range l.entries {
+		if entry.matches(query) {
+			matched = append(matched, entry)
+		}
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].path < matched[j].path
+	})
+
+	return matched
+}
+
+// guildLibrary returns the library of the given guild, creating and scanning it the first time it's needed.
+func (p *Plugin) guildLibrary(guildId string) *library {
+	// Anything else would end up somewhere other than a guild's own directory, e.g. libraryDir itself for ""
+	if !isGuildId(guildId) {
+		return nil
+	}
+
+	p.librariesMu.Lock()
+	defer p.librariesMu.Unlock()
+
+	if l, ok := p.libraries.Get(guildId); ok {
+		return l
+	}
+
+	l := newLibrary(filepath.Join(libraryDir, guildId), false, p.logger)
+	if err := os.MkdirAll(l.dir, 0744); err != nil {
+		l.logger.Error("failed to create library dir", slog.String("error", err.Error()))
+	}
+	l.scan()
+	p.libraries.Set(guildId, l)
+
+	return l
+}
+
+// globalLibrary returns the library shared by every guild, or nil if it's disabled.
+func (p *Plugin) globalLibrary() *library {
+	if strings.ToLower(p.config.GlobalLibrary) != "true" {
+		return nil
+	}
+
+	return p.global
+}
+
+// localLibraries returns every library the given guild can play from, its own first. There are none without a guild.
+func (p *Plugin) localLibraries(guildId string) []*library {
+	l := p.guildLibrary(guildId)
+	if l == nil {
+		return nil
+	}
+
+	libraries := []*library{l}
+	if global := p.globalLibrary(); global != nil {
+		libraries = append(libraries, global)
+	}
+
+	return libraries
+}
+
+// findLocalFile returns the entry for name as the given guild sees it. Names marked with globalFilePrefix are only looked
+// for in the global library, anything else is looked for in the guild's own library before the global one.
+func (p *Plugin) findLocalFile(guildId string, name string) (libraryEntry, bool) {
+	if name, ok := strings.CutPrefix(strings.TrimSpace(name), globalFilePrefix); ok {
+		if global := p.globalLibrary(); global != nil {
+			return global.find(name)
+		}
+		return libraryEntry{}, false
+	}
+
+	for _, l := range p.localLibraries(guildId) {
+		if entry, ok := l.find(name); ok {
+			return entry, true
+		}
+	}
+
+	return libraryEntry{}, false
+}
+
+// ownLocalFile is findLocalFile for changing a file. Files in the global library have to be asked for with
+// globalFilePrefix, so a guild's files are never mixed up with the global ones.
+func (p *Plugin) ownLocalFile(guildId string, name string) (*library, libraryEntry, bool) {
+	l := p.guildLibrary(guildId)
+	if l == nil {
+		return nil, libraryEntry{}, false
+	}
+
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(name), globalFilePrefix); ok {
+		if l = p.globalLibrary(); l == nil {
+			return nil, libraryEntry{}, false
+		}
+		name = rest
+	}
+
+	entry, ok := l.find(name)
+
+	return l, entry, ok
+}
+
+// searchLocalFiles searches every library the given guild can play from, with the guild's own files first.
+func (p *Plugin) searchLocalFiles(guildId string, query string) []libraryEntry {
+	var entries []libraryEntry
+	for _, l := range p.localLibraries(guildId) {
+		entries = append(entries, l.search(query)...)
+	}
+
+	return entries
+}
+
+// listLocalFiles lists every library the given guild can play from, with the guild's own files first.
+func (p *Plugin) listLocalFiles(guildId string, query string) []libraryEntry {
+	var entries []libraryEntry
+	for _, l := range p.localLibraries(guildId) {
+		entries = append(entries, l.list(query)...)
+	}
+
+	return entries
+}
+
+// migrateLibrary moves anything left at the top of libraryDir from before libraries were split by guild into the global
+// library, since every guild could already play it.
+func (p *Plugin) migrateLibrary() {
+	dirEntries, err := os.ReadDir(libraryDir)
+	if err != nil {
+		p.logger.Error("failed to read library dir", slog.String("error", err.Error()))
+		return
+	}
+
+	for _, d := range dirEntries {
+		if d.Name() == globalLibraryName || (d.IsDir() && isGuildId(d.Name())) {
+			continue
+		}
+
+		from := filepath.Join(libraryDir, d.Name())
+		to, err := freeFilePath(p.global.dir, d.Name())
+		if err == nil {
+			err = os.Rename(from, to)
+		}
+		if err != nil {
+			p.logger.Error("failed to move file into global library",
+				slog.String("error", err.Error()),
+				slog.String("path", from),
+			)
+			continue
+		}
+
+		p.logger.Info("moved file into global library", slog.String("from", from), slog.String("to", to))
+	}
+}
+
+// migratedPath maps a path from before libraries were split by guild to where migrateLibrary moved it. Any other path
+// is returned unchanged.
+func migratedPath(path string) string {
+	rel, err := filepath.Rel(libraryDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	first, _, nested := strings.Cut(filepath.ToSlash(rel), "/")
+	if first == globalLibraryName || (nested && isGuildId(first)) {
+		return path
+	}
+
+	return filepath.Join(libraryDir, globalLibraryName, rel)
+}
+
+// isGuildId reports whether s looks like a discord id.
+func isGuildId(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
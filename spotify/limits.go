@@ -0,0 +1,57 @@
+package spotify
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/olympus-go/apollo"
+	"golang.org/x/exp/slices"
+)
+
+// checkQueueRoom returns the response explaining why userId can't add another track to the session's queue, or "" if
+// they can. Limits that are unset or invalid in the config aren't enforced, and admins bypass them entirely.
+func (p *Plugin) checkQueueRoom(s *session, userId string) string {
+	if slices.Contains(p.config.AdminIds, userId) {
+		return ""
+	}
+
+	upcoming := s.player.List(false)
+
+	if limit, err := strconv.Atoi(p.config.MaxQueueLength); err == nil && limit > 0 && len(upcoming) >= limit {
+		return p.config.PlayCommand.Responses.QueueFull
+	}
+
+	if limit, err := strconv.Atoi(p.config.MaxQueuedPerRequester); err == nil && limit > 0 {
+		queued := 0
+		for _, t := range upcoming {
+			if t.Metadata()["requesterId"] == userId {
+				queued++
+			}
+		}
+
+		if queued >= limit {
+			return p.config.PlayCommand.Responses.RequesterLimitReached
+		}
+	}
+
+	return ""
+}
+
+// checkTrackDuration returns the response explaining why userId can't queue t because of its length, or "" if they can.
+// MaxTrackDuration is a Go duration string (e.g. "10m").
+func (p *Plugin) checkTrackDuration(t apollo.Playable, userId string) string {
+	if slices.Contains(p.config.AdminIds, userId) {
+		return ""
+	}
+
+	limit, err := time.ParseDuration(p.config.MaxTrackDuration)
+	if err != nil || limit <= 0 {
+		return ""
+	}
+
+	if t.Duration() > limit {
+		return p.config.PlayCommand.Responses.TrackTooLong
+	}
+
+	return ""
+}
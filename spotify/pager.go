@@ -0,0 +1,127 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/apollo"
+)
+
+// queuePageSize is how many tracks are listed on each page of the queue and history.
+const queuePageSize = 10
+
+// Views that can be paged through.
+const (
+	queueView   = "queue"
+	historyView = "history"
+)
+
+// renderPage renders the requested page of view as an embed, along with the buttons to move between pages. The page is
+// clamped to the pages that actually exist, since the queue may have changed since the buttons were sent. false is
+// returned if there's nothing to show.
+//
+// Everything needed to render the next page is encoded in the custom ID of each button (e.g.
+// spotify_page_queue_next_3), so several people can page through the same queue without sharing any state.
+func (s *session) renderPage(view string, page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent, bool) {
+	var tracks []apollo.Playable
+	var header string
+	embed := &discordgo.MessageEmbed{}
+
+	switch view {
+	case historyView:
+		tracks = s.player.List(true)
+		embed.Title = "History"
+	default:
+		view = queueView
+		np, ok := s.player.NowPlaying()
+		if !ok {
+			return nil, nil, false
+		}
+
+		tracks = s.player.List(false)
+		embed.Title = "Queue"
+		header = nowPlayingHeader(np, s.codec.Position())
+	}
+
+	if len(tracks) == 0 && header == "" {
+		return nil, nil, false
+	}
+
+	pages := max((len(tracks)+queuePageSize-1)/queuePageSize, 1)
+	page = min(max(page, 0), pages-1)
+	offset := page * queuePageSize
+
+	var lines []string
+	for index, t := range tracks[offset:min(offset+queuePageSize, len(tracks))] {
+		lines = append(lines, fmt.Sprintf("`%d.` **%s%s** - %s `%s` (@%s)",
+			offset+index+1, t.Name(), remixName(t), t.Artist(),
+			t.Duration().Round(time.Second), t.Metadata()["requesterName"]))
+	}
+
+	if header != "" {
+		embed.Description = header
+		if len(lines) > 0 {
+			embed.Description += "\n\n**Up next:**\n"
+		}
+	}
+	embed.Description += strings.Join(lines, "\n")
+
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("Page %d/%d | %d tracks | %s remaining | Loop: %s",
+			page+1, pages, len(tracks), s.remaining().Round(time.Second), s.loopMode),
+	}
+
+	row := discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			pageButton(view, "first", 0, "First", page > 0),
+			pageButton(view, "prev", page-1, "Prev", page > 0),
+			pageButton(view, "next", page+1, "Next", page < pages-1),
+			pageButton(view, "last", pages-1, "Last", page < pages-1),
+		},
+	}
+
+	return embed, []discordgo.MessageComponent{row}, true
+}
+
+// remaining returns how long it'll take to play the rest of the current track and everything after it.
+func (s *session) remaining() time.Duration {
+	var total time.Duration
+	if np, ok := s.player.NowPlaying(); ok {
+		total += max(np.Duration()-s.codec.Position(), 0)
+	}
+
+	for _, t := range s.player.List(false) {
+		total += t.Duration()
+	}
+
+	return total
+}
+
+// nowPlayingHeader describes np along with a progress bar for how much of it has played.
+func nowPlayingHeader(np apollo.Playable, position time.Duration) string {
+	elapsedDuration := position.Round(time.Second)
+	totalDuration := np.Duration().Round(time.Second)
+	elapsedPercent := 1.0
+	if totalDuration > 0 {
+		elapsedPercent = min(elapsedDuration.Seconds()/totalDuration.Seconds(), 1)
+	}
+
+	return fmt.Sprintf("**Now playing:** %s%s - %s (@%s)\n`<%s%s> [%s/%s]`",
+		np.Name(), remixName(np), np.Artist(), np.Metadata()["requesterName"],
+		strings.Repeat("\u2588", int(elapsedPercent*30)),
+		strings.Repeat("\u2591", int(30-(elapsedPercent*30))),
+		elapsedDuration.String(),
+		totalDuration.String(),
+	)
+}
+
+func pageButton(view string, kind string, page int, label string, enabled bool) discordgo.Button {
+	return discordgo.Button{
+		Label:    label,
+		Style:    discordgo.SecondaryButton,
+		CustomID: fmt.Sprintf("spotify_page_%s_%s_%d", view, kind, page),
+		Disabled: !enabled,
+	}
+}
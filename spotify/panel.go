@@ -0,0 +1,212 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/apollo"
+	"github.com/olympus-go/eris/utils"
+)
+
+// panelRefreshInterval is how often the now playing panel checks the player for changes nobody told it about. Anything
+// that changes playback calls panelChanged instead, so this is only a fallback.
+const panelRefreshInterval = 30 * time.Second
+
+// panelSettleDelay is how long the panel waits after being told something changed before refreshing. Changes that come
+// in the meantime are folded into the same edit, and it gives the player time to settle on its new state.
+const panelSettleDelay = time.Second
+
+// nowPlayingPanel is a message that's kept up to date with whatever is currently playing, along with buttons to control
+// playback. The message is only posted once something starts playing.
+type nowPlayingPanel struct {
+	mu             sync.Mutex
+	discordSession *discordgo.Session
+	channelId      string
+	messageId      string
+	// content is what the panel is currently showing, used to skip edits when nothing has changed.
+	content string
+	// changed is signalled by panelChanged.
+	changed chan struct{}
+	// stopped is set once the panel has been removed, so a refresh that was already underway doesn't post it again.
+	stopped bool
+	cancel  context.CancelFunc
+}
+
+// startPanel starts keeping a now playing panel in channelId. Any existing panel is removed first.
+func (s *session) startPanel(discordSession *discordgo.Session, channelId string) {
+	s.stopPanel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	panel := &nowPlayingPanel{
+		discordSession: discordSession,
+		channelId:      channelId,
+		changed:        make(chan struct{}, 1),
+		cancel:         cancel,
+	}
+
+	s.panelMu.Lock()
+	s.panel = panel
+	s.panelMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(panelRefreshInterval)
+		defer ticker.Stop()
+
+		// Show whatever is playing right away rather than waiting for the first change
+		s.refreshPanel(panel)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-panel.changed:
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(panelSettleDelay):
+				}
+			}
+
+			s.refreshPanel(panel)
+		}
+	}()
+}
+
+// panelChanged tells the now playing panel that playback has changed, if there is one.
+func (s *session) panelChanged() {
+	s.panelMu.Lock()
+	panel := s.panel
+	s.panelMu.Unlock()
+
+	if panel == nil {
+		return
+	}
+
+	// A change is already waiting to be picked up if this would block
+	select {
+	case panel.changed <- struct{}{}:
+	default:
+	}
+}
+
+// hasPanel reports whether the session has a now playing panel.
+func (s *session) hasPanel() bool {
+	s.panelMu.Lock()
+	defer s.panelMu.Unlock()
+
+	return s.panel != nil
+}
+
+// stopPanel stops updating the now playing panel and deletes its message.
+func (s *session) stopPanel() {
+	s.panelMu.Lock()
+	panel := s.panel
+	s.panel = nil
+	s.panelMu.Unlock()
+
+	if panel == nil {
+		return
+	}
+
+	panel.cancel()
+
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+
+	panel.stopped = true
+	if panel.messageId != "" {
+		_ = panel.discordSession.ChannelMessageDelete(panel.channelId, panel.messageId)
+		panel.messageId = ""
+	}
+}
+
+// refreshPanel updates panel to match the current state of the player, posting it if it hasn't been already.
+func (s *session) refreshPanel(panel *nowPlayingPanel) {
+	panel.mu.Lock()
+	defer panel.mu.Unlock()
+
+	if panel.stopped {
+		return
+	}
+
+	np, playing := s.player.NowPlaying()
+
+	// Nothing to show until playback starts
+	if panel.messageId == "" && !playing {
+		return
+	}
+
+	content, components := s.renderPanel(np, playing)
+	if content == panel.content {
+		return
+	}
+
+	if panel.messageId == "" {
+		message, err := panel.discordSession.ChannelMessageSendComplex(panel.channelId, &discordgo.MessageSend{
+			Content:    content,
+			Components: components,
+		})
+		if err != nil {
+			return
+		}
+		panel.messageId = message.ID
+	} else {
+		edit := discordgo.NewMessageEdit(panel.channelId, panel.messageId).SetContent(content)
+		edit.Components = &components
+		if _, err := panel.discordSession.ChannelMessageEditComplex(edit); err != nil {
+			return
+		}
+	}
+
+	panel.content = content
+}
+
+func (s *session) renderPanel(np apollo.Playable, playing bool) (string, []discordgo.MessageComponent) {
+	// Don't give away the answers
+	if s.quizGame != nil {
+		return ":game_die: Quiz in progress.", []discordgo.MessageComponent{}
+	}
+
+	var content string
+	if playing {
+		state := ":arrow_forward:"
+		if s.player.State() == apollo.PauseState {
+			state = ":pause_button:"
+		}
+
+		content = fmt.Sprintf("%s **%s%s** - %s\nRequested by @%s | Loop: %s",
+			state, np.Name(), remixName(np), np.Artist(), np.Metadata()["requesterName"], s.loopMode)
+	} else {
+		content = fmt.Sprintf(":stop_button: Nothing playing.\nLoop: %s", s.loopMode)
+	}
+
+	toggle := utils.Button().Id("spotify_panel_pause").Label("Pause").Style(discordgo.SecondaryButton)
+	if s.player.State() == apollo.PauseState {
+		toggle = utils.Button().Id("spotify_panel_resume").Label("Resume").Style(discordgo.PrimaryButton)
+	}
+
+	row := utils.ActionsRow().
+		Button(utils.Button().Id("spotify_panel_previous").Label("Previous").Style(discordgo.SecondaryButton).Build()).
+		Button(toggle.Enabled(playing).Build()).
+		Button(utils.Button().Id("spotify_panel_next").Label("Next").Style(discordgo.SecondaryButton).Enabled(playing).Build()).
+		Button(utils.Button().Id("spotify_panel_shuffle").Label("Shuffle").Style(discordgo.SecondaryButton).Build()).
+		Button(utils.Button().Id("spotify_panel_loop").Label("Loop: " + s.loopMode).Style(discordgo.SecondaryButton).Build())
+
+	return content, []discordgo.MessageComponent{row.Build()}
+}
+
+// nextLoopMode returns the loop mode that follows mode when cycling through them.
+func nextLoopMode(mode string) string {
+	switch mode {
+	case loopOff:
+		return loopTrack
+	case loopTrack:
+		return loopQueue
+	default:
+		return loopOff
+	}
+}
@@ -4,34 +4,55 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/eolso/threadsafe"
+	"github.com/olympus-go/apollo"
+	"github.com/olympus-go/apollo/spotify"
 )
 
 const queryLimit = 10
 const sessionTimeout = 5 * time.Minute
+const snapshotInterval = 30 * time.Second
 
 var alphanumericRegex *regexp.Regexp
 
 type Plugin struct {
-	sessions *threadsafe.Map[string, *session]
-	config   *Config
-	logger   *slog.Logger
+	sessions  *threadsafe.Map[string, *session]
+	libraries *threadsafe.Map[string, *library]
+	// librariesMu guards creating guild libraries, so each one is only scanned once.
+	librariesMu sync.Mutex
+	global      *library
+	bans        *banStore
+	banPatterns []*regexp.Regexp
+	web         *webApi
+	config      *Config
+	logger      *slog.Logger
 }
 
 // NewPlugin creates a new spotify.Plugin. If no logging is desired, a zerolog.Nop() should be supplied.
 func NewPlugin(config *Config, h slog.Handler) *Plugin {
 	plugin := Plugin{
-		sessions: threadsafe.NewMap[string, *session](),
-		config:   config,
-		logger:   slog.New(h).With(slog.String("plugin", "spotify")),
+		sessions:  threadsafe.NewMap[string, *session](),
+		libraries: threadsafe.NewMap[string, *library](),
+		bans:      newBanStore(),
+		web:       newWebApi(config),
+		config:    config,
+		logger:    slog.New(h).With(slog.String("plugin", "spotify")),
 	}
 
+	plugin.banPatterns = compileBanPatterns(config.BannedPatterns, plugin.logger)
+	plugin.global = newLibrary(filepath.Join(libraryDir, globalLibraryName), true, plugin.logger)
+
 	plugin.fileUploadHandlerInit()
 	_ = plugin.pruneSessions(context.Background())
+	_ = plugin.snapshotSessions(context.Background())
 
 	return &plugin
 }
@@ -48,7 +69,10 @@ func (p *Plugin) Handlers() map[string]any {
 	handlers := make(map[string]any)
 
 	handlers["spotify_handler"] = p.spotifyHandler
-	handlers["spotify_file_upload_handler"] = p.fileUploadHandler
+	// The files subcommands do the same thing without needing to read every message
+	if p.messageTriggers() {
+		handlers["spotify_file_upload_handler"] = p.fileUploadHandler
+	}
 
 	return handlers
 }
@@ -75,6 +99,17 @@ func (p *Plugin) Commands() map[string]*discordgo.ApplicationCommand {
 			p.listifyCommand(),
 			p.clearCommand(),
 			p.shuffleCommand(),
+			p.loopCommand(),
+			p.seekCommand(),
+			p.volumeCommand(),
+			p.moveCommand(),
+			p.banCommand(),
+			p.unbanCommand(),
+			p.autoplayCommand(),
+			p.guildPlaylistCommand(),
+			p.filesCommand(),
+			p.exportCommand(),
+			p.importCommand(),
 		},
 	}
 
@@ -82,21 +117,40 @@ func (p *Plugin) Commands() map[string]*discordgo.ApplicationCommand {
 }
 
 func (p *Plugin) Intents() []discordgo.Intent {
-	return []discordgo.Intent{
+	intents := []discordgo.Intent{
 		discordgo.IntentsGuilds,
 		discordgo.IntentsGuildMessages,
 		discordgo.IntentsGuildVoiceStates,
-		discordgo.IntentMessageContent,
 	}
+
+	if p.messageTriggers() {
+		intents = append(intents, discordgo.IntentMessageContent)
+	}
+
+	return intents
+}
+
+// messageTriggers reports whether local files can still be managed by talking to the bot, rather than only through
+// the files subcommands.
+func (p *Plugin) messageTriggers() bool {
+	return strings.ToLower(p.config.MessageTriggers) == "true"
 }
 
 func (p *Plugin) fileUploadHandlerInit() {
-	err := os.MkdirAll("downloads", 0744)
+	err := os.MkdirAll(p.global.dir, 0744)
 	if err != nil {
 		p.logger.Error("failed to create downloads dir",
 			slog.String("error", err.Error()),
 		)
 	}
+	// Without the global library there's nowhere for the old files to go, so they're left where they are
+	if p.globalLibrary() != nil {
+		p.migrateLibrary()
+	}
+
+	// Done up front so that restored queues and the first searches can already find global files. Guild libraries are
+	// scanned the first time they're used.
+	p.global.scan()
 
 	alphanumericRegex, err = regexp.Compile(`[^a-zA-Z0-9 ]+`)
 	if err != nil {
@@ -106,6 +160,47 @@ func (p *Plugin) fileUploadHandlerInit() {
 	}
 }
 
+// createSession creates a new session for the guild, applies the configured defaults to it, and stores it.
+func (p *Plugin) createSession(guildId string) *session {
+	sessionConfig := spotify.DefaultSessionConfig()
+	sessionConfig.ConfigHomeDir = guildDir(guildId)
+	sessionConfig.OAuthCallback = p.config.SpotifyCallbackUrl
+	s := newSession(guildId, sessionConfig, p.logger.Handler(), p.config.AdminIds...)
+
+	volume, err := strconv.Atoi(p.config.DefaultVolume)
+	if err != nil {
+		volume = 100
+	}
+	s.setVolume(volume)
+
+	// An empty or invalid target leaves normalization disabled
+	target, err := strconv.ParseFloat(p.config.LoudnessTarget, 64)
+	s.codec.filter.SetLoudnessTarget(target, err == nil)
+
+	s.autoplay = strings.ToLower(p.config.Autoplay) == "true"
+	s.autoplaySeeds, err = strconv.Atoi(p.config.AutoplaySeeds)
+	if err != nil || s.autoplaySeeds < 1 {
+		s.autoplaySeeds = 5
+	}
+	s.banned = func(playable apollo.Playable) bool {
+		return p.isBanned(guildId, playable)
+	}
+	s.libraries = p.localLibraries(guildId)
+
+	// Pick up where the guild left off if the bot was restarted. This happens before the session is stored so that
+	// nothing can be queued ahead of the restored tracks.
+	if err = p.restoreSession(s); err != nil {
+		p.logger.Error("failed to restore queue snapshot",
+			slog.String("error", err.Error()),
+			slog.String("guild_id", guildId),
+		)
+	}
+
+	p.sessions.Set(guildId, s)
+
+	return s
+}
+
 func (p *Plugin) pruneSessions(ctx context.Context) context.CancelFunc {
 	c, cancel := context.WithCancel(ctx)
 
@@ -119,6 +214,12 @@ func (p *Plugin) pruneSessions(ctx context.Context) context.CancelFunc {
 				for i := 0; i < len(k); i++ {
 					if v[i].voiceConnection == nil {
 						if v[i].timeLastJoined.Before(time.Now().Add(-1 * sessionTimeout)) {
+							if err := v[i].saveSnapshot(); err != nil {
+								p.logger.Error("failed to save queue snapshot",
+									slog.String("error", err.Error()),
+									slog.String("guild_id", k[i]),
+								)
+							}
 							p.sessions.Delete(k[i])
 						}
 					}
@@ -129,3 +230,29 @@ func (p *Plugin) pruneSessions(ctx context.Context) context.CancelFunc {
 
 	return cancel
 }
+
+// snapshotSessions periodically persists every session's queue so that it can be restored after a restart.
+func (p *Plugin) snapshotSessions(ctx context.Context) context.CancelFunc {
+	c, cancel := context.WithCancel(ctx)
+
+	go func() {
+		for {
+			select {
+			case <-c.Done():
+				return
+			case <-time.Tick(snapshotInterval):
+				k, v := p.sessions.Items()
+				for i := 0; i < len(k); i++ {
+					if err := v[i].saveSnapshot(); err != nil {
+						p.logger.Error("failed to save queue snapshot",
+							slog.String("error", err.Error()),
+							slog.String("guild_id", k[i]),
+						)
+					}
+				}
+			}
+		}
+	}()
+
+	return cancel
+}
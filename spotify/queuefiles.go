@@ -0,0 +1,171 @@
+package spotify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/olympus-go/apollo"
+	"github.com/olympus-go/apollo/spotify"
+)
+
+// maxQueueFileSize is the largest queue file that will be downloaded for an import.
+const maxQueueFileSize = 1 << 20
+
+// queueFile is the JSON form of an exported queue.
+type queueFile struct {
+	Tracks []queueFileEntry `json:"tracks"`
+}
+
+// queueFileEntry is a single track in an exported queue. Exactly one of Uri or File is set, the rest is only kept so the
+// file makes sense to whoever is reading it.
+type queueFileEntry struct {
+	Uri           string `json:"uri,omitempty"`
+	File          string `json:"file,omitempty"`
+	Name          string `json:"name"`
+	Artist        string `json:"artist"`
+	Duration      int    `json:"duration"`
+	RequesterId   string `json:"requester_id"`
+	RequesterName string `json:"requester_name"`
+}
+
+// queueFileLine is an entry read back from a queue file, along with where it was found so failures can be reported.
+type queueFileLine struct {
+	line  int
+	text  string
+	entry queueFileEntry
+}
+
+// queueFileEntries converts tracks to entries for a queue file, skipping anything that can't be exported.
+func queueFileEntries(tracks []apollo.Playable) []queueFileEntry {
+	var entries []queueFileEntry
+	for _, t := range tracks {
+		entry, ok := playlistEntryFor(t)
+		if !ok {
+			continue
+		}
+
+		exported := queueFileEntry{
+			File:          entry.File,
+			Name:          t.Name(),
+			Artist:        t.Artist(),
+			Duration:      int(t.Duration().Seconds()),
+			RequesterId:   t.Metadata()["requesterId"],
+			RequesterName: t.Metadata()["requesterName"],
+		}
+		if entry.TrackId != "" {
+			exported.Uri = spotify.Uri{Scheme: "spotify", Authority: spotify.TrackResourceType, Path: entry.TrackId}.String()
+		}
+
+		entries = append(entries, exported)
+	}
+
+	return entries
+}
+
+// encodeM3U8 writes entries as an extended M3U playlist. Requesters are kept in a #EXTERIS line before each entry, which
+// other players (and imports) ignore.
+func encodeM3U8(entries []queueFileEntry) []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "#EXTINF:%d,%s - %s\n", entry.Duration, entry.Artist, entry.Name)
+		fmt.Fprintf(&b, "#EXTERIS:%s,%s\n", entry.RequesterId, entry.RequesterName)
+		if entry.Uri != "" {
+			b.WriteString(entry.Uri + "\n")
+		} else {
+			b.WriteString(entry.File + "\n")
+		}
+	}
+
+	return b.Bytes()
+}
+
+// encodeQueueJSON writes entries as a queueFile.
+func encodeQueueJSON(entries []queueFileEntry) ([]byte, error) {
+	return json.MarshalIndent(queueFile{Tracks: entries}, "", "  ")
+}
+
+// decodeQueueFile reads the entries from a queue file exported by either encodeM3U8 or encodeQueueJSON. The format is
+// picked from filename, falling back to sniffing the contents.
+func decodeQueueFile(filename string, b []byte) ([]queueFileLine, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return decodeQueueJSON(b)
+	case ".m3u", ".m3u8":
+		return decodeM3U8(b)
+	}
+
+	if trimmed := bytes.TrimSpace(b); bytes.HasPrefix(trimmed, []byte("{")) {
+		return decodeQueueJSON(b)
+	}
+
+	return decodeM3U8(b)
+}
+
+// decodeQueueJSON reads the entries from a queueFile. Entries are numbered from 1 in place of line numbers.
+func decodeQueueJSON(b []byte) ([]queueFileLine, error) {
+	var file queueFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+
+	lines := make([]queueFileLine, 0, len(file.Tracks))
+	for index, entry := range file.Tracks {
+		text := entry.Uri
+		if text == "" {
+			text = entry.File
+		}
+
+		lines = append(lines, queueFileLine{line: index + 1, text: text, entry: entry})
+	}
+
+	return lines, nil
+}
+
+// decodeM3U8 reads the entries from an M3U playlist. Spotify URIs and links are read as tracks, and anything else is
+// taken to be the name of a local file. Comments are ignored.
+func decodeM3U8(b []byte) ([]queueFileLine, error) {
+	var lines []queueFileLine
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var entry queueFileEntry
+		if uri, ok := spotify.ConvertLinkToUri(text); ok {
+			entry.Uri = uri.String()
+		} else if strings.HasPrefix(text, "spotify:") {
+			entry.Uri = text
+		} else {
+			// Paths are kept relative to the library, though a bare file name is also enough to find a file
+			file := strings.ReplaceAll(text, "\\", "/")
+			entry.File = strings.TrimPrefix(file, libraryDir+"/")
+		}
+
+		lines = append(lines, queueFileLine{line: lineNumber, text: text, entry: entry})
+	}
+
+	return lines, scanner.Err()
+}
+
+// queueId returns the id enqueueTrackIds expects for e, or false if e doesn't refer to a track.
+func (e queueFileEntry) queueId() (string, bool) {
+	if e.File != "" {
+		return localFilePrefix + e.File, true
+	}
+
+	uri := spotify.NewUri(e.Uri)
+	if uri.Scheme != "spotify" || uri.Authority != spotify.TrackResourceType || uri.Path == "" {
+		return "", false
+	}
+
+	return uri.Path, true
+}
@@ -0,0 +1,90 @@
+package spotify
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestDecodeM3U8(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []queueFileLine
+	}{
+		{"empty", "", nil},
+		{"only comments", "#EXTM3U\n#EXTINF:123,Artist - Name\n\n", nil},
+		{
+			"spotify uri",
+			"#EXTM3U\n#EXTINF:123,Artist - Name\nspotify:track:abc\n",
+			[]queueFileLine{{line: 3, text: "spotify:track:abc", entry: queueFileEntry{Uri: "spotify:track:abc"}}},
+		},
+		{
+			"spotify link",
+			"https://open.spotify.com/track/abc?si=123",
+			[]queueFileLine{{line: 1, text: "https://open.spotify.com/track/abc?si=123", entry: queueFileEntry{Uri: "spotify:track:abc"}}},
+		},
+		{
+			"library path",
+			"downloads/guild/song.mp3",
+			[]queueFileLine{{line: 1, text: "downloads/guild/song.mp3", entry: queueFileEntry{File: "guild/song.mp3"}}},
+		},
+		{
+			"windows path",
+			`downloads\guild\song.mp3`,
+			[]queueFileLine{{line: 1, text: `downloads\guild\song.mp3`, entry: queueFileEntry{File: "guild/song.mp3"}}},
+		},
+		{
+			"bare file name",
+			"song.mp3",
+			[]queueFileLine{{line: 1, text: "song.mp3", entry: queueFileEntry{File: "song.mp3"}}},
+		},
+		{
+			"line numbers skip blanks and comments",
+			"#EXTM3U\n\n  spotify:track:abc  \r\n#EXTERIS:1,someone\nsong.mp3\n",
+			[]queueFileLine{
+				{line: 3, text: "spotify:track:abc", entry: queueFileEntry{Uri: "spotify:track:abc"}},
+				{line: 5, text: "song.mp3", entry: queueFileEntry{File: "song.mp3"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeM3U8([]byte(test.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueueFileEntryQueueId(t *testing.T) {
+	tests := []struct {
+		name   string
+		entry  queueFileEntry
+		want   string
+		wantOk bool
+	}{
+		{"track", queueFileEntry{Uri: "spotify:track:abc"}, "abc", true},
+		{"file", queueFileEntry{File: "guild/song.mp3"}, localFilePrefix + "guild/song.mp3", true},
+		{"file takes precedence", queueFileEntry{Uri: "spotify:track:abc", File: "song.mp3"}, localFilePrefix + "song.mp3", true},
+		{"album", queueFileEntry{Uri: "spotify:album:abc"}, "", false},
+		{"missing id", queueFileEntry{Uri: "spotify:track:"}, "", false},
+		{"other scheme", queueFileEntry{Uri: "youtube:track:abc"}, "", false},
+		{"not a uri", queueFileEntry{Uri: "abc"}, "", false},
+		{"empty", queueFileEntry{}, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.entry.queueId()
+			if got != test.want || ok != test.wantOk {
+				t.Errorf("got %q, %t, want %q, %t", got, ok, test.want, test.wantOk)
+			}
+		})
+	}
+}
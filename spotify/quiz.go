@@ -37,6 +37,8 @@ type quiz struct {
 
 	rng        *rand.Rand
 	cancelFunc context.CancelFunc
+	// banned is used to keep banned tracks out of questions.
+	banned func(spotify.Track) bool
 }
 
 // validatePlaylist checks if the playlist is still in a good state.
@@ -50,17 +52,28 @@ func (s *quiz) getRandomTracks(player *spotify.Session, n int) []spotify.Track {
 	}
 
 	randomIndexes := make(map[int]bool)
-	for len(randomIndexes) < n {
-		randomIndexes[s.rng.Intn(len(s.playlist))] = true
-	}
-
 	var tracks []spotify.Track
-	for key, _ := range randomIndexes {
-		t, err := player.GetTrackById(s.playlist[key])
+	for len(tracks) < n {
+		// Ran out of tracks to pick from
+		if len(randomIndexes) >= len(s.playlist) {
+			return nil
+		}
+
+		index := s.rng.Intn(len(s.playlist))
+		if randomIndexes[index] {
+			continue
+		}
+		randomIndexes[index] = true
+
+		t, err := player.GetTrackById(s.playlist[index])
 		if err != nil {
 			return nil
 		}
 
+		if s.banned != nil && s.banned(t) {
+			continue
+		}
+
 		tracks = append(tracks, t)
 	}
 
@@ -1,9 +1,23 @@
 package spotify
 
 import (
+	"math"
 	"time"
 )
 
+// Resampler quality presets. Higher qualities use longer filters, which gives a sharper cutoff and better stopband
+// attenuation at the cost of more work per sample.
+const (
+	QualityLow = iota + 1
+	QualityMedium
+	QualityHigh
+	QualityBest
+)
+
+// maxPhases is the largest number of filter phases that will be precomputed. Conversions between rates that would
+// need more than this (e.g. 48000 -> 44101) calculate their coefficients on the fly instead.
+const maxPhases = 4096
+
 type Resampler struct {
 	in          <-chan [][]float32
 	out         chan [][]float32
@@ -13,6 +27,9 @@ type Resampler struct {
 	throttle    time.Duration
 }
 
+// NewResampler creates a Resampler that converts samples received on stream from the current rate to the target
+// rate. Samples are sent as [sample][channel]. quality should be one of the Quality presets, values outside that range
+// are clamped to the nearest preset.
 func NewResampler(stream <-chan [][]float32, current int, target int, quality int) *Resampler {
 	// Negative and zero value quality isn't really a thing. Default it to 1.
 	if quality <= 0 {
@@ -41,151 +58,295 @@ func (r *Resampler) SamplesOut() <-chan [][]float32 {
 	return r.out
 }
 
+// Resample reads from the input stream until it's closed, sending resampled samples to SamplesOut as they become
+// available. SamplesOut is closed once everything has been sent.
 func (r *Resampler) Resample() {
 	if r.currentRate == r.targetRate {
 		r.dontResample()
 		return
 	}
 
-	// Allocate a slice of points to use for interpolation
-	points := make([]point, r.quality*2)
+	stream := newResampleStream(newResampleFilter(r.currentRate, r.targetRate, r.quality))
 
-	// Keep track of the current total offset of the entire track. This is moved chunks at a time.
-	offset := 0
+	for samples := range r.in {
+		if resampled := stream.process(samples); len(resampled) > 0 {
+			r.out <- resampled
+		}
 
-	// Keep track of the individual number of samples processed.
-	samplesProcessed := 0
+		// Throttle if requested. This is useful for when you want resample fast but not max out available cores.
+		time.Sleep(r.throttle)
+	}
 
-	// Calculate the resample ratio
-	ratio := float64(r.currentRate) / float64(r.targetRate)
+	if resampled := stream.flush(); len(resampled) > 0 {
+		r.out <- resampled
+	}
 
-	// For this to work we will need two buffers worth of data because as we approach the end of the current buffer we
-	// will need data points from future samples in order to appropriate calculate the interpolation of the point.
-	//
-	// At the very beginning of the track though we can just store the same buffer in each twice. This logic may change,
-	// but it makes the most sense at the moment.
+	close(r.out)
+}
 
-	// Create a buffer for storing the previous sample.
-	var previousSamples [][]float32
-	var currentSamples [][]float32
-	var resampled [][]float32
-	var ok bool
-
-	// Begin iterating over the in channel until it's closed.
-	for {
-		// At the very beginning of processing, we should just duplicate the data in currentSample to previousSample.
-		if samplesProcessed == 0 {
-			currentSamples, ok = <-r.in
-
-			// Verify that the channel isn't closed, and we weren't sent an empty slice.
-			if !ok || currentSamples == nil || len(currentSamples) == 0 {
-				close(r.out)
-				return
-			}
+// ResampleAll is a convenience function for resampling a complete set of samples so no channels are needed.
+// The output is identical to what Resample would send for the same samples, regardless of how they were chunked.
+func (r *Resampler) ResampleAll(samples [][]float32) [][]float32 {
+	return Resample(samples, r.currentRate, r.targetRate, r.quality)
+}
 
-			previousSamples = make([][]float32, len(currentSamples))
-			for i := range previousSamples {
-				previousSamples[i] = make([]float32, len(currentSamples[0]))
-			}
-			copy(previousSamples, currentSamples)
+// Resample converts a complete set of samples from the old rate to the new rate.
+func Resample(samples [][]float32, old int, new int, quality int) [][]float32 {
+	if old == new {
+		return samples
+	}
+
+	stream := newResampleStream(newResampleFilter(old, new, quality))
+
+	resampled := stream.process(samples)
+	resampled = append(resampled, stream.flush()...)
+
+	return resampled
+}
+
+func (r *Resampler) dontResample() {
+	for sample := range r.in {
+		r.out <- sample
+	}
+	close(r.out)
+}
+
+// resampleFilter is a Kaiser windowed sinc low pass filter split into polyphase form. Converting from current to
+// target is treated as upsampling by up and then downsampling by down, where up/down is target/current in lowest
+// terms. Every output sample then lands on one of up phases, each of which gets its own set of taps.
+type resampleFilter struct {
+	up, down int
+	// halfLen is the number of input samples used on either side of an output sample.
+	halfLen int
+	cutoff  float64
+	beta    float64
+	// phases holds the precomputed taps for each phase, or nil if there would be too many of them.
+	phases [][]float32
+}
+
+// resamplePreset holds the filter design parameters for a quality preset.
+type resamplePreset struct {
+	halfLen int
+	// rolloff is where the cutoff sits relative to the lower of the two nyquist frequencies.
+	rolloff float64
+	beta    float64
+}
+
+var resamplePresets = map[int]resamplePreset{
+	QualityLow:    {halfLen: 8, rolloff: 0.85, beta: 6},
+	QualityMedium: {halfLen: 16, rolloff: 0.9, beta: 7},
+	QualityHigh:   {halfLen: 32, rolloff: 0.94, beta: 8.6},
+	QualityBest:   {halfLen: 64, rolloff: 0.96, beta: 10},
+}
+
+func newResampleFilter(current int, target int, quality int) *resampleFilter {
+	preset := resamplePresets[min(max(quality, QualityLow), QualityBest)]
+
+	g := gcd(current, target)
+	f := &resampleFilter{
+		up:      target / g,
+		down:    current / g,
+		halfLen: preset.halfLen,
+		cutoff:  preset.rolloff * math.Min(1, float64(target)/float64(current)),
+		beta:    preset.beta,
+	}
+
+	if f.up <= maxPhases {
+		f.phases = make([][]float32, f.up)
+		for phase := range f.phases {
+			f.phases[phase] = f.taps(phase)
 		}
+	}
+
+	return f
+}
+
+// taps calculates the coefficients for phase, normalized so each phase has unity gain at DC.
+func (f *resampleFilter) taps(phase int) []float32 {
+	taps := make([]float32, 2*f.halfLen)
+	frac := float64(phase) / float64(f.up)
+
+	sum := 0.0
+	values := make([]float64, len(taps))
+	for k := range values {
+		// Distance between the input sample for this tap and the output sample.
+		x := float64(k-f.halfLen+1) - frac
+		values[k] = f.cutoff * sinc(f.cutoff*x) * kaiser(x/float64(f.halfLen), f.beta)
+		sum += values[k]
+	}
+
+	for k := range taps {
+		taps[k] = float32(values[k] / sum)
+	}
+
+	return taps
+}
+
+func (f *resampleFilter) phase(phase int) []float32 {
+	if f.phases != nil {
+		return f.phases[phase]
+	}
+
+	return f.taps(phase)
+}
 
-		// We need to remake this every time because slices are passed by reference inherently. We don't want to assume
-		// the consumer of the out channel is keeping up with the sends.
-		resampled = make([][]float32, len(currentSamples))
-		for i := range resampled {
-			resampled[i] = make([]float32, len(currentSamples[0]))
+// resampleStream holds the state needed to resample a stream of samples one chunk at a time. Output doesn't depend on
+// how the input was chunked.
+type resampleStream struct {
+	filter *resampleFilter
+	// buf holds the input samples that may still be needed, as [channel][sample].
+	buf [][]float32
+	// next is the position of the next output sample in buf, in units of 1/filter.up input samples.
+	next int64
+	// consumed and produced count the total number of input samples received and output samples created.
+	consumed int64
+	produced int64
+}
+
+func newResampleStream(filter *resampleFilter) *resampleStream {
+	return &resampleStream{
+		filter: filter,
+		next:   int64(filter.halfLen-1) * int64(filter.up),
+	}
+}
+
+// process adds samples to the stream and returns every output sample that can be fully calculated so far.
+func (s *resampleStream) process(samples [][]float32) [][]float32 {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	if s.buf == nil {
+		// Pad the start with silence so the first output samples have a full window.
+		s.buf = make([][]float32, len(samples[0]))
+		for ch := range s.buf {
+			s.buf[ch] = make([]float32, s.filter.halfLen-1, s.filter.halfLen-1+len(samples))
 		}
+	}
 
-		for sampleIndex, sample := range currentSamples {
-			for channelIndex := range sample {
-				scaledOffset := float64(samplesProcessed) * ratio
-
-				// Build the set of surrounding points to calculate the value for the sample.
-				for pIndex := range points {
-					// Calculate the index of the sample to add to the set
-					i := int(scaledOffset) + pIndex - len(points)/2 + 1
-
-					switch {
-					// The sample is in previousSamples.
-					case i < offset:
-						points[pIndex] = point{X: float32(i), Y: previousSamples[len(previousSamples)+i-offset][channelIndex]}
-					// The sample is in currentSamples.
-					case i < offset+len(currentSamples):
-						points[pIndex] = point{X: float32(i), Y: currentSamples[i-offset][channelIndex]}
-					// The sample is beyond currentSamples.
-					default:
-						offset += len(currentSamples)
-						previousSamples, currentSamples = currentSamples, previousSamples
-
-						// There's nothing more to process, time to exit.
-						if currentSamples, ok = <-r.in; !ok {
-							close(r.out)
-							return
-						}
-
-						points[pIndex] = point{X: float32(i), Y: currentSamples[i-offset][channelIndex]}
-					}
-				}
-				resampled[sampleIndex][channelIndex] = LagInt(points, float32(scaledOffset))
+	for _, sample := range samples {
+		for ch := range s.buf {
+			var v float32
+			if ch < len(sample) {
+				v = sample[ch]
 			}
-			samplesProcessed++
+			s.buf[ch] = append(s.buf[ch], v)
 		}
-		r.out <- resampled
-
-		// Throttle if requested. This is useful for when you want resample fast but not max out available cores.
-		time.Sleep(r.throttle)
 	}
-}
+	s.consumed += int64(len(samples))
 
-// ResampleAll is a convenience function for resampling a complete set of samples so no channels are needed.
-func (r *Resampler) ResampleAll(samples [][]float32) [][]float32 {
-	return nil
+	return s.drain(-1)
 }
 
-func Resample(samples [][]float32, old int, new int, quality int) [][]float32 {
-	ratio := float64(old) / float64(new)
-	points := make([]point, quality*2)
+// flush returns the remaining output samples, treating everything after the end of the stream as silence.
+func (s *resampleStream) flush() [][]float32 {
+	if s.buf == nil {
+		return nil
+	}
 
-	resampled := make([][]float32, 0, len(samples))
-	for i := range resampled {
-		resampled[i] = make([]float32, 0, len(samples[0]))
+	for ch := range s.buf {
+		s.buf[ch] = append(s.buf[ch], make([]float32, s.filter.halfLen)...)
 	}
 
-	for i := 0; ; i++ {
-		j := float64(i) * ratio
-		if int(j) >= len(samples) {
+	// Output sample n sits at input position n*down/up, so only positions before the end of the input count.
+	total := (s.consumed*int64(s.filter.up) + int64(s.filter.down) - 1) / int64(s.filter.down)
+
+	return s.drain(total - s.produced)
+}
+
+// drain calculates output samples until the filter window runs past the end of buf, or limit samples have been
+// created. A negative limit means no limit.
+func (s *resampleStream) drain(limit int64) [][]float32 {
+	up := int64(s.filter.up)
+	down := int64(s.filter.down)
+	halfLen := int64(s.filter.halfLen)
+	available := int64(len(s.buf[0]))
+
+	var resampled [][]float32
+	for limit != 0 {
+		center := s.next / up
+		if center+halfLen >= available {
 			break
 		}
-		sample := make([]float32, len(samples[0]))
-		for channelIndex := range sample {
-			for pIndex := range points {
-				k := int(j) + pIndex - len(points)/2 + 1
-				if k >= 0 && k < len(samples) {
-					points[pIndex] = point{X: float32(k), Y: samples[k][channelIndex]}
-				} else {
-					points[pIndex] = point{X: float32(k), Y: 0}
-				}
+
+		taps := s.filter.phase(int(s.next % up))
+		start := center - halfLen + 1
+
+		sample := make([]float32, len(s.buf))
+		for ch := range s.buf {
+			window := s.buf[ch][start : start+int64(len(taps))]
+
+			var y float32
+			for k, tap := range taps {
+				y += tap * window[k]
 			}
-			y := LagInt(points[:], float32(j))
-			sample[channelIndex] = y
+			sample[ch] = y
 		}
 		resampled = append(resampled, sample)
+
+		s.next += down
+		s.produced++
+		limit--
+	}
+
+	// Drop input samples that no future output sample will need.
+	if drop := s.next/up - halfLen + 1; drop > 0 {
+		for ch := range s.buf {
+			s.buf[ch] = append(s.buf[ch][:0], s.buf[ch][drop:]...)
+		}
+		s.next -= drop * up
 	}
 
 	return resampled
 }
 
-func (r *Resampler) dontResample() {
-	for sample := range r.in {
-		r.out <- sample
+// sinc is the normalized sinc function sin(pi*x)/(pi*x).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
 	}
-	close(r.out)
+
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser evaluates the Kaiser window with shape beta at x, where x is in [-1, 1].
+func kaiser(x float64, beta float64) float64 {
+	if x < -1 || x > 1 {
+		return 0
+	}
+
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
 }
 
 // LagInt calculates the Lagrange interpolating polynomial y for x in a given set of points. Useful for resampling
 // stereo audio.
 // Read more here: https://en.wikipedia.org/wiki/Lagrange_polynomial.
+//
+// Deprecated: Resampler no longer uses Lagrange interpolation, which rings badly at higher qualities. LagInt is only
+// kept for existing callers and will be removed in a future release.
 func LagInt(pts []point, x float32) (y float32) {
 	y = float32(0.0)
 
@@ -202,6 +363,7 @@ func LagInt(pts []point, x float32) (y float32) {
 	return y
 }
 
+// Deprecated: point is only used by LagInt.
 type point struct {
 	X, Y float32
 }
@@ -0,0 +1,299 @@
+package spotify
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+var resampleConversions = []struct {
+	current, target int
+}{
+	{48000, 40000},
+	{48000, 56000},
+	{40000, 48000},
+	{56000, 48000},
+}
+
+// sine generates n stereo samples of a sine wave at freq Hz, sampled at rate.
+func sine(n int, freq float64, rate int) [][]float32 {
+	samples := make([][]float32, n)
+	for i := range samples {
+		v := float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+		samples[i] = []float32{v, v}
+	}
+
+	return samples
+}
+
+// amplitude measures the amplitude of freq Hz in the first channel of samples, sampled at rate. The edges are skipped
+// so the filter's warm up doesn't skew the result.
+func amplitude(samples [][]float32, freq float64, rate int) float64 {
+	skip := len(samples) / 10
+	var re, im float64
+	for i := skip; i < len(samples)-skip; i++ {
+		phase := 2 * math.Pi * freq * float64(i) / float64(rate)
+		re += float64(samples[i][0]) * math.Cos(phase)
+		im += float64(samples[i][0]) * math.Sin(phase)
+	}
+
+	return 2 * math.Hypot(re, im) / float64(len(samples)-2*skip)
+}
+
+func TestResampleLength(t *testing.T) {
+	for _, c := range resampleConversions {
+		for _, n := range []int{1, 7, 960, 48000, 48001} {
+			t.Run(fmt.Sprintf("%d->%d/%d", c.current, c.target, n), func(t *testing.T) {
+				want := int(math.Ceil(float64(n) * float64(c.target) / float64(c.current)))
+
+				if got := len(Resample(sine(n, 440, c.current), c.current, c.target, QualityHigh)); got != want {
+					t.Errorf("got %d samples, want %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestResampleFrequencyResponse(t *testing.T) {
+	for _, c := range resampleConversions {
+		nyquist := float64(min(c.current, c.target)) / 2
+
+		for quality := QualityLow; quality <= QualityBest; quality++ {
+			t.Run(fmt.Sprintf("%d->%d/q%d", c.current, c.target, quality), func(t *testing.T) {
+				// Anything comfortably inside the passband should come out at the same amplitude and frequency.
+				for _, freq := range []float64{100, 1000, 10000} {
+					out := Resample(sine(c.current, freq, c.current), c.current, c.target, quality)
+
+					if db := 20 * math.Log10(amplitude(out, freq, c.target)/0.5); math.Abs(db) > 0.1 {
+						t.Errorf("%.0fHz: passband gain %.3fdB, want within 0.1dB", freq, db)
+					}
+				}
+
+				// Anything above the lower nyquist frequency should be filtered out rather than aliased.
+				if c.target < c.current {
+					freq := nyquist + float64(c.current)/2*0.1
+					out := Resample(sine(c.current, freq, c.current), c.current, c.target, quality)
+					alias := float64(c.target) - freq
+
+					if db := 20 * math.Log10(amplitude(out, alias, c.target)/0.5); db > -40 {
+						t.Errorf("%.0fHz: aliased to %.0fHz at %.1fdB, want below -40dB", freq, alias, db)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestResampleSameRate(t *testing.T) {
+	samples := sine(100, 440, 48000)
+	out := Resample(samples, 48000, 48000, QualityHigh)
+
+	if len(out) != len(samples) {
+		t.Fatalf("got %d samples, want %d", len(out), len(samples))
+	}
+}
+
+func BenchmarkResample(b *testing.B) {
+	for _, c := range resampleConversions {
+		samples := sine(c.current, 1000, c.current)
+
+		for quality := QualityLow; quality <= QualityBest; quality++ {
+			b.Run(fmt.Sprintf("%d->%d/q%d", c.current, c.target, quality), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					Resample(samples, c.current, c.target, quality)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkResamplerStream(b *testing.B) {
+	samples := sine(48000, 1000, 48000)
+
+	for i := 0; i < b.N; i++ {
+		in := make(chan [][]float32, 10)
+		r := NewResampler(in, 48000, 40000, QualityHigh)
+		go r.Resample()
+
+		go func() {
+			for start := 0; start < len(samples); start += 960 {
+				in <- samples[start:min(start+960, len(samples))]
+			}
+			close(in)
+		}()
+
+		for range r.SamplesOut() {
+		}
+	}
+}
+
+// stream sends samples through a Resampler in chunks of size and collects everything it sends back.
+func stream(r *Resampler, in chan [][]float32, samples [][]float32, size int) [][]float32 {
+	go r.Resample()
+
+	go func() {
+		for start := 0; start < len(samples); start += size {
+			in <- samples[start:min(start+size, len(samples))]
+		}
+		close(in)
+	}()
+
+	var out [][]float32
+	for chunk := range r.SamplesOut() {
+		out = append(out, chunk...)
+	}
+
+	return out
+}
+
+// noise generates n samples with the given number of channels, with each channel holding a different signal.
+func noise(n int, channels int) [][]float32 {
+	samples := make([][]float32, n)
+	seed := uint32(1)
+	for i := range samples {
+		samples[i] = make([]float32, channels)
+		for ch := range samples[i] {
+			seed = seed*1664525 + 1013904223
+			samples[i][ch] = float32(seed>>8)/float32(1<<24) - 0.5
+		}
+	}
+
+	return samples
+}
+
+func equalSamples(t *testing.T, got [][]float32, want [][]float32) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("sample %d: got %d channels, want %d", i, len(got[i]), len(want[i]))
+		}
+		for ch := range want[i] {
+			if got[i][ch] != want[i][ch] {
+				t.Fatalf("sample %d channel %d: got %v, want %v", i, ch, got[i][ch], want[i][ch])
+			}
+		}
+	}
+}
+
+func TestResamplerParity(t *testing.T) {
+	conversions := append(resampleConversions, struct{ current, target int }{44100, 48000})
+
+	for _, c := range conversions {
+		for _, channels := range []int{1, 2} {
+			samples := noise(4801, channels)
+			want := NewResampler(nil, c.current, c.target, QualityHigh).ResampleAll(samples)
+
+			// Odd chunk sizes make sure filter windows and phases straddle buffer boundaries.
+			for _, size := range []int{1, 3, 31, 960, 1024, len(samples)} {
+				t.Run(fmt.Sprintf("%d->%d/%dch/%d", c.current, c.target, channels, size), func(t *testing.T) {
+					in := make(chan [][]float32)
+					got := stream(NewResampler(in, c.current, c.target, QualityHigh), in, samples, size)
+
+					equalSamples(t, got, want)
+				})
+			}
+		}
+	}
+}
+
+func TestResamplerEmpty(t *testing.T) {
+	if out := NewResampler(nil, 48000, 40000, QualityHigh).ResampleAll(nil); len(out) != 0 {
+		t.Errorf("ResampleAll: got %d samples, want 0", len(out))
+	}
+
+	// An input channel that's closed without ever sending anything should close the output straight away.
+	in := make(chan [][]float32)
+	close(in)
+	r := NewResampler(in, 48000, 40000, QualityHigh)
+	go r.Resample()
+
+	if _, ok := <-r.SamplesOut(); ok {
+		t.Error("Resample: got samples from a closed input channel")
+	}
+
+	// Empty chunks mixed into the stream shouldn't change anything.
+	samples := noise(100, 2)
+	in = make(chan [][]float32, 4)
+	in <- nil
+	in <- samples[:50]
+	in <- [][]float32{}
+	in <- samples[50:]
+	close(in)
+
+	r = NewResampler(in, 48000, 40000, QualityHigh)
+	go r.Resample()
+
+	var got [][]float32
+	for chunk := range r.SamplesOut() {
+		got = append(got, chunk...)
+	}
+
+	equalSamples(t, got, r.ResampleAll(samples))
+}
+
+func TestResamplerRatio(t *testing.T) {
+	samples := noise(1000, 2)
+
+	for _, c := range resampleConversions {
+		out := NewResampler(nil, c.current, c.target, QualityHigh).ResampleAll(samples)
+
+		ratio := float64(c.target) / float64(c.current)
+		if ratio > 1 && len(out) <= len(samples) || ratio < 1 && len(out) >= len(samples) {
+			t.Errorf("%d->%d: got %d samples from %d", c.current, c.target, len(out), len(samples))
+		}
+	}
+}
+
+func TestResamplerSameRate(t *testing.T) {
+	samples := noise(100, 2)
+
+	in := make(chan [][]float32)
+	got := stream(NewResampler(in, 48000, 48000, QualityHigh), in, samples, 7)
+
+	equalSamples(t, got, samples)
+	equalSamples(t, NewResampler(nil, 48000, 48000, QualityHigh).ResampleAll(samples), samples)
+}
+
+func TestResamplerSetBufferSize(t *testing.T) {
+	in := make(chan [][]float32)
+	r := NewResampler(in, 48000, 40000, QualityHigh)
+
+	if got := cap(r.out); got != 10 {
+		t.Errorf("default buffer size: got %d, want 10", got)
+	}
+
+	r.SetBufferSize(3)
+	if got := cap(r.out); got != 3 {
+		t.Errorf("buffer size: got %d, want 3", got)
+	}
+
+	// Output should still be complete with an unbuffered channel.
+	r.SetBufferSize(0)
+	samples := noise(500, 2)
+	equalSamples(t, stream(r, in, samples, 10), r.ResampleAll(samples))
+}
+
+func TestResamplerSetThrottle(t *testing.T) {
+	const chunks = 5
+	const throttle = 10 * time.Millisecond
+
+	in := make(chan [][]float32)
+	r := NewResampler(in, 48000, 40000, QualityLow)
+	r.SetThrottle(throttle)
+
+	samples := noise(chunks*10, 2)
+
+	start := time.Now()
+	got := stream(r, in, samples, 10)
+	if elapsed := time.Since(start); elapsed < chunks*throttle {
+		t.Errorf("took %s, want at least %s", elapsed, chunks*throttle)
+	}
+
+	equalSamples(t, got, r.ResampleAll(samples))
+}
@@ -2,7 +2,13 @@ package spotify
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -21,25 +27,73 @@ const (
 	choppedFrequency   = 56000
 )
 
+const (
+	loopOff   = "off"
+	loopTrack = "track"
+	loopQueue = "queue"
+)
+
 type track struct {
 	spotify.Track
 	metadata map[string]string
 }
 
+// localFile wraps apollo.LocalFile so that its metadata travels along with its download. Where the file was tagged,
+// entry overrides what apollo worked out on its own.
+type localFile struct {
+	apollo.LocalFile
+	entry libraryEntry
+}
+
+// metadataReader is returned by Download so that the codec can see the metadata of what it's opening.
+type metadataReader struct {
+	io.ReadCloser
+	metadata map[string]string
+}
+
 type playInteraction struct {
 	trackIds []string
-	// playlistName is set when a playlist was sent. "" == not a playlist.
-	playlistName string
-	position     int
-	frequency    int
+	// collection is set when a playlist, album or artist was sent. nil == not a collection.
+	collection *collection
+	position   int
+	frequency  int
+	// localFile is set when a local file that's already queued is waiting on confirmation.
+	localFile *localFile
+	// results are the search results as select menu options, in the same order they were returned.
+	results []discordgo.SelectMenuOption
+	// multi is set when the select menu should let several results be picked at once.
+	multi bool
 }
 
 type session struct {
 	session *spotify.Session
 	player  *apollo.Player
+	codec   *trackCodec
 
 	playInteractions *threadsafe.Map[string, playInteraction]
-	quizGame         *quiz
+	// queueMu is held while the upcoming tracks are being reordered, see rebalance.
+	queueMu sync.Mutex
+	// pinned holds the tracks that were inserted at a specific position, which fair queueing leaves in place.
+	pinned   *threadsafe.Map[apollo.Playable, bool]
+	quizGame *quiz
+	// voteMu guards skipVote, which is shared between every interaction voting on it.
+	voteMu   sync.Mutex
+	skipVote *skipVote
+	// panelMu guards panel, which is also cleared from its own goroutine.
+	panelMu  sync.Mutex
+	panel    *nowPlayingPanel
+	loopMode string
+	// volume is the playback volume as a percentage.
+	volume int
+	// autoplay keeps queueing related tracks once the queue runs dry, seeded from the last autoplaySeeds tracks played.
+	autoplay      bool
+	autoplaySeeds int
+	// autoplayFilling is set while fillAutoplay is looking for something to queue.
+	autoplayFilling atomic.Bool
+	// banned is used to keep banned tracks out of autoplay.
+	banned func(apollo.Playable) bool
+	// libraries are where autoplay picks local files from.
+	libraries []*library
 
 	guildId         string
 	voiceConnection *discordgo.VoiceConnection
@@ -49,6 +103,13 @@ type session struct {
 	cancelVoiceTimeout context.CancelFunc
 	cancelVoiceSend    context.CancelFunc
 	timeLastJoined     time.Time
+
+	// snapshotPath is where the queue is persisted between restarts. restored is set once the snapshot has been loaded
+	// back into the player, or given up on because something else was queued first. Nothing is saved until it's set.
+	// snapshotMu guards restored and serializes saves and restores, which run from several goroutines.
+	snapshotPath string
+	snapshotMu   sync.Mutex
+	restored     bool
 }
 
 func newSession(guildId string, sessionConfig spotify.SessionConfig, h slog.Handler, adminIds ...string) *session {
@@ -63,18 +124,28 @@ func newSession(guildId string, sessionConfig spotify.SessionConfig, h slog.Hand
 		CompressionLevel: "10",
 	}
 
-	codec := ffmpeg.New(opts).WithCodec(&ogg.Decoder{})
+	codec := newTrackCodec(opts)
 	playerConfig := apollo.PlayerConfig{PacketBuffer: ogg.MaxPageSize}
 	player := apollo.NewPlayer(playerConfig, h).WithCodec(codec)
 
-	return &session{
+	s := &session{
 		session:          spotify.NewSession(sessionConfig, h),
 		player:           player,
+		codec:            codec,
 		playInteractions: threadsafe.NewMap[string, playInteraction](),
+		pinned:           threadsafe.NewMap[apollo.Playable, bool](),
+		loopMode:         loopOff,
+		volume:           100,
 		guildId:          guildId,
 		voiceConnection:  nil,
 		adminIds:         adminIds,
+		snapshotPath:     filepath.Join(sessionConfig.ConfigHomeDir, snapshotFilename),
 	}
+	codec.onFinish = s.trackFinished
+	codec.onOpen = s.panelChanged
+	codec.onClose = s.waitForQueue
+
+	return s
 }
 
 func (s *session) joinVoice(discordSession *discordgo.Session, interaction *discordgo.Interaction) error {
@@ -142,6 +213,7 @@ func (s *session) leaveVoice() error {
 	}
 
 	s.stop()
+	s.stopPanel()
 
 	if err := s.voiceConnection.Disconnect(); err != nil {
 		return err
@@ -194,6 +266,81 @@ func (s *session) stop() {
 	}
 }
 
+// setVolume sets the playback volume as a percentage of the original.
+func (s *session) setVolume(percent int) {
+	s.volume = max(percent, 0)
+	s.codec.filter.SetVolume(s.volume)
+}
+
+// trackFinished is called right before the player moves on from a track that played to completion. It requeues the
+// track according to the session's loop mode, and keeps autoplay going.
+func (s *session) trackFinished() {
+	np, ok := s.player.NowPlaying()
+	if !ok {
+		return
+	}
+
+	s.queueMu.Lock()
+	switch s.loopMode {
+	case loopTrack:
+		// Pinned so a rebalance doesn't move it away from being up next
+		s.player.Insert(s.player.Cursor(), np)
+		s.pin(np)
+	case loopQueue:
+		s.player.Enqueue(np)
+	}
+	s.queueMu.Unlock()
+
+	s.fillAutoplay()
+	s.panelChanged()
+}
+
+// skip moves on from t, which is expected to be what's currently playing.
+func (s *session) skip(t apollo.Playable) {
+	// Skipped tracks still need to make it back around when looping the queue
+	if s.loopMode == loopQueue {
+		s.player.Enqueue(t)
+	}
+	s.fillAutoplay()
+
+	s.player.Next()
+	s.panelChanged()
+}
+
+// moveRange moves count tracks starting at queue index from so that the first of them ends up at index to. Moved tracks
+// are pinned so fair queueing leaves them where they were put.
+func (s *session) moveRange(from int, to int, count int) {
+	if to < from {
+		for k := 0; k < count; k++ {
+			s.move(from+k, to+k)
+		}
+	} else {
+		// Work backwards so the tracks still waiting to be moved don't shift
+		for k := count - 1; k >= 0; k-- {
+			s.move(from+k, to+k)
+		}
+	}
+}
+
+// move moves the track at queue index from to index to. It's inserted at its new position before being removed from its
+// old one so the queue is never missing anything.
+func (s *session) move(from int, to int) {
+	t := s.player.Get(from)
+	if t == nil || from == to {
+		return
+	}
+
+	if to < from {
+		s.player.Insert(to, t)
+		s.player.Remove(from + 1)
+	} else {
+		s.player.Insert(to+1, t)
+		s.player.Remove(from)
+	}
+
+	s.pin(t)
+}
+
 func (s *session) checkPermissions(p apollo.Playable, userId string) bool {
 	if requesterId, ok := p.Metadata()["requesterId"]; ok {
 		if requesterId == userId {
@@ -247,27 +394,144 @@ func (s *session) timeoutVoice(ctx context.Context, discordSession *discordgo.Se
 	return cancel, nil
 }
 
-func yesNoButtons(uid string, enabled bool) []discordgo.MessageComponent {
-	return []discordgo.MessageComponent{
+// expirePlayInteraction deletes the play interaction for uid, along with the response to interaction, if it hasn't been
+// finished within a minute.
+func (s *session) expirePlayInteraction(discordSession *discordgo.Session, interaction *discordgo.Interaction, uid string, logger *slog.Logger) {
+	go func() {
+		time.Sleep(60 * time.Second)
+		if _, ok := s.playInteractions.Get(uid); ok {
+			utils.InteractionResponse(discordSession, interaction).DeleteWithLog(logger)
+			s.playInteractions.Delete(uid)
+			logger.Debug("play interaction timed out", slog.String("uid", uid))
+		}
+	}()
+}
+
+// searchComponents returns the components for a search prompt: yes/no buttons for the result being shown, and a select
+// menu listing every result so users don't have to page through them one at a time.
+func searchComponents(uid string, interaction playInteraction) []discordgo.MessageComponent {
+	toggleLabel := "Pick several"
+	if interaction.multi {
+		toggleLabel = "Pick one"
+	}
+
+	components := []discordgo.MessageComponent{
 		discordgo.ActionsRow{
 			Components: []discordgo.MessageComponent{
 				discordgo.Button{
 					Label:    "Yes",
 					Style:    discordgo.PrimaryButton,
 					CustomID: "spotify_play_yes_" + uid,
-					Disabled: !enabled,
 				},
 				discordgo.Button{
 					Label:    "No",
 					Style:    discordgo.SecondaryButton,
 					CustomID: "spotify_play_no_" + uid,
-					Disabled: !enabled,
+				},
+				discordgo.Button{
+					Label:    toggleLabel,
+					Style:    discordgo.SecondaryButton,
+					CustomID: "spotify_play_multi_" + uid,
+					Disabled: len(interaction.results) < 2,
 				},
 			},
 		},
 	}
+
+	if len(interaction.results) == 0 {
+		return components
+	}
+
+	menu := discordgo.SelectMenu{
+		CustomID:    "spotify_play_select_" + uid,
+		Placeholder: "Or pick one of the results",
+		MinValues:   utils.PointerTo(1),
+		MaxValues:   1,
+		Options:     interaction.results,
+	}
+	if interaction.multi {
+		menu.Placeholder = "Or pick any of the results"
+		menu.MaxValues = len(interaction.results)
+	}
+
+	return append(components, discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}})
+}
+
+// resultOption returns t as an option for the search results select menu.
+func resultOption(t spotify.Track) discordgo.SelectMenuOption {
+	return discordgo.SelectMenuOption{
+		Label:       truncate(t.Name(), maxChoiceLength),
+		Value:       t.Id(),
+		Description: truncate(fmt.Sprintf("%s - %s", t.Artist(), t.Duration().Round(time.Second)), maxChoiceLength),
+	}
 }
 
 func (t track) Metadata() map[string]string {
 	return t.metadata
 }
+
+func (t track) Download() (io.ReadCloser, error) {
+	r, err := t.Track.Download()
+	if err != nil {
+		return nil, err
+	}
+
+	return metadataReader{ReadCloser: r, metadata: t.metadata}, nil
+}
+
+func (l localFile) Name() string {
+	if l.entry.title != "" {
+		return l.entry.title
+	}
+
+	return l.LocalFile.Name()
+}
+
+func (l localFile) Artist() string {
+	if l.entry.artist != "" {
+		return l.entry.artist
+	}
+
+	return l.LocalFile.Artist()
+}
+
+func (l localFile) Album() string {
+	if l.entry.album != "" {
+		return l.entry.album
+	}
+
+	return l.LocalFile.Album()
+}
+
+func (l localFile) Duration() time.Duration {
+	if l.entry.duration > 0 {
+		return l.entry.duration
+	}
+
+	return l.LocalFile.Duration()
+}
+
+func (l localFile) Download() (io.ReadCloser, error) {
+	r, err := l.LocalFile.Download()
+	if err != nil {
+		return nil, err
+	}
+
+	return metadataReader{ReadCloser: r, metadata: l.Mdata}, nil
+}
+
+func (m metadataReader) Metadata() map[string]string {
+	return m.metadata
+}
+
+// remixName returns a label for the remix applied to p, or "" if there isn't one.
+func remixName(p apollo.Playable) string {
+	switch p.Metadata()["frequency"] {
+	case strconv.Itoa(nightcoreFrequency):
+		return " (nightcore)"
+	case strconv.Itoa(choppedFrequency):
+		return " (chopped and screwed)"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,223 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/olympus-go/apollo"
+)
+
+const snapshotFilename = "queue.json"
+
+// queueSnapshot is the on-disk representation of a guild's queue.
+type queueSnapshot struct {
+	// Cursor is the player cursor at the time of the snapshot.
+	Cursor int `json:"cursor"`
+	// Playing is true when the track at Cursor-1 was playing (or paused) when the snapshot was taken.
+	Playing bool `json:"playing"`
+	// Elapsed is how far into the playing track the player was.
+	Elapsed time.Duration   `json:"elapsed"`
+	Tracks  []snapshotEntry `json:"tracks"`
+}
+
+// snapshotEntry holds enough information to rebuild a single apollo.Playable. Exactly one of TrackId or Path is set.
+type snapshotEntry struct {
+	TrackId  string            `json:"track_id,omitempty"`
+	Path     string            `json:"path,omitempty"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// saveSnapshot writes the session's entire queue to disk. Sessions that haven't been restored yet are skipped, that way
+// a failed restore doesn't clobber the previous snapshot.
+func (s *session) saveSnapshot() error {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	if s.snapshotPath == "" || !s.restored {
+		return nil
+	}
+
+	queue := s.player.List(true)
+
+	snapshot := queueSnapshot{
+		Cursor: s.player.Cursor(),
+		Tracks: make([]snapshotEntry, 0, len(queue)),
+	}
+
+	if _, ok := s.player.NowPlaying(); ok {
+		snapshot.Playing = true
+		snapshot.Elapsed = s.codec.Position()
+	}
+
+	for _, playable := range queue {
+		entry := snapshotEntry{Metadata: playable.Metadata()}
+
+		switch t := playable.(type) {
+		case *track:
+			entry.TrackId = t.Id()
+		case *localFile:
+			entry.Path = t.Metadata()["path"]
+		}
+
+		snapshot.Tracks = append(snapshot.Tracks, entry)
+	}
+
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash mid-write never leaves a truncated snapshot behind.
+	tmpPath := s.snapshotPath + ".tmp"
+	if err = os.WriteFile(tmpPath, b, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, s.snapshotPath)
+}
+
+// loadSnapshot reads the session's snapshot from disk. A missing snapshot returns an empty queueSnapshot.
+func (s *session) loadSnapshot() (queueSnapshot, error) {
+	var snapshot queueSnapshot
+
+	b, err := os.ReadFile(s.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, nil
+	} else if err != nil {
+		return snapshot, err
+	}
+
+	err = json.Unmarshal(b, &snapshot)
+
+	return snapshot, err
+}
+
+// restoreSession enqueues the tracks from a guild's snapshot, starting with whatever was playing when it was taken.
+// Tracks that have since been banned or deleted from the library are skipped. apollo.Player doesn't expose a way to
+// move its cursor, so history before the cursor isn't restored.
+func (p *Plugin) restoreSession(s *session) error {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	if s.restored {
+		return nil
+	}
+
+	// Whatever was queued since an earlier restore failed takes precedence. Restoring now would put the old queue after
+	// it and resume the wrong track part way through.
+	if len(s.player.List(true)) > 0 {
+		s.restored = true
+		return nil
+	}
+
+	snapshot, err := s.loadSnapshot()
+	if err != nil {
+		return err
+	}
+
+	if len(snapshot.Tracks) == 0 {
+		s.restored = true
+		return nil
+	}
+
+	if !s.session.LoggedIn() {
+		if err = s.session.Login("georgetuney"); err != nil {
+			return fmt.Errorf("failed to login: %w", err)
+		}
+	}
+
+	start := snapshot.Cursor
+	if snapshot.Playing {
+		start--
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	// Resolve everything before touching the player so a periodic snapshot never catches a half restored queue.
+	var playables []apollo.Playable
+	for index, entry := range snapshot.Tracks[min(start, len(snapshot.Tracks)):] {
+		playable, ok := p.restoreEntry(s, entry)
+		if !ok {
+			continue
+		}
+
+		// Resume the interrupted track where it left off, as long as it's still around to be played.
+		if index == 0 && snapshot.Playing {
+			s.codec.SetStartOffset(snapshot.Elapsed)
+		}
+
+		playables = append(playables, playable)
+	}
+
+	for _, playable := range playables {
+		s.player.Enqueue(playable)
+	}
+	p.rebalanceQueue(s)
+
+	s.restored = true
+
+	return nil
+}
+
+// restoreEntry rebuilds the apollo.Playable for a single snapshotEntry. ok is false if the entry can no longer be
+// played.
+func (p *Plugin) restoreEntry(s *session, entry snapshotEntry) (apollo.Playable, bool) {
+	switch {
+	case entry.TrackId != "":
+		if p.isBannedTrack(s.guildId, entry.TrackId) {
+			return nil, false
+		}
+
+		spotTrack, err := s.session.GetTrackById(entry.TrackId)
+		if err != nil {
+			p.logger.Error("failed to restore track",
+				slog.String("error", err.Error()),
+				slog.String("trackId", entry.TrackId),
+			)
+			return nil, false
+		}
+
+		t := &track{Track: spotTrack, metadata: entry.Metadata}
+		if p.isBanned(s.guildId, t) {
+			return nil, false
+		}
+
+		return t, true
+	case entry.Path != "":
+		// Only restore files that are still in the library. Snapshots from before libraries were split by guild point
+		// to where the file was before it was migrated.
+		path := migratedPath(entry.Path)
+		var fileEntry libraryEntry
+		for _, l := range p.localLibraries(s.guildId) {
+			if rel, ok := l.relPath(path); ok {
+				fileEntry, ok = l.find(rel)
+				if !ok || fileEntry.path != rel {
+					return nil, false
+				}
+				break
+			}
+		}
+		if fileEntry.path == "" {
+			return nil, false
+		}
+
+		file, err := fileEntry.open("", "")
+		if err != nil {
+			return nil, false
+		}
+		file.Mdata = entry.Metadata
+
+		if p.isBanned(s.guildId, &file) {
+			return nil, false
+		}
+
+		return &file, true
+	}
+
+	return nil, false
+}
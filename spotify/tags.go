@@ -0,0 +1,627 @@
+package spotify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// maxTagSize is the largest tag that will be read into memory. Anything bigger is almost certainly a huge piece of
+// artwork, so the file is treated as untagged rather than risk holding it all.
+const maxTagSize = 16 << 20
+
+var errNoTags = errors.New("no supported tags found")
+
+// audioTags is the metadata embedded in an audio file. Anything that couldn't be found is left empty.
+type audioTags struct {
+	title    string
+	artist   string
+	album    string
+	duration time.Duration
+	// artwork is the raw image embedded in the file, with artworkMime being its MIME type.
+	artwork     []byte
+	artworkMime string
+}
+
+// readTags reads the tags embedded in the file at path. ID3v2 (mp3), FLAC and Ogg (vorbis and opus) are supported,
+// errNoTags is returned for anything else.
+func readTags(path string) (audioTags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return audioTags{}, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err = io.ReadFull(f, magic); err != nil {
+		return audioTags{}, errNoTags
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return audioTags{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("ID3")):
+		return readID3v2(f)
+	case bytes.Equal(magic, []byte("fLaC")):
+		return readFLAC(f)
+	case bytes.Equal(magic, []byte("OggS")):
+		return readOgg(f)
+	}
+
+	return audioTags{}, errNoTags
+}
+
+// readID3v2 reads the ID3v2 tag at the start of f. If the tag doesn't include a length, the duration is worked out from
+// the mp3 frames that follow it instead.
+func readID3v2(f *os.File) (audioTags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return audioTags{}, err
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if version < 2 || version > 4 || size > maxTagSize {
+		return audioTags{}, errNoTags
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return audioTags{}, err
+	}
+
+	// Version 4 unsynchronises each frame separately instead
+	if flags&0x80 != 0 && version < 4 {
+		data = unsynchronise(data)
+	}
+
+	if flags&0x40 != 0 && version > 2 && len(data) >= 4 {
+		// The extended header doesn't count its own size in version 3
+		extendedSize := int(binary.BigEndian.Uint32(data[:4])) + 4
+		if version == 4 {
+			extendedSize = int(syncsafe(data[:4]))
+		}
+		data = data[min(extendedSize, len(data)):]
+	}
+
+	var tags audioTags
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for len(data) >= headerSize && data[0] != 0 {
+		id := string(data[:idSize])
+
+		var frameSize int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			frameSize = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[4:8]))
+			frameFlags = binary.BigEndian.Uint16(data[8:10])
+		default:
+			frameSize = int(syncsafe(data[4:8]))
+			frameFlags = binary.BigEndian.Uint16(data[8:10])
+		}
+
+		if frameSize > len(data)-headerSize {
+			break
+		}
+		frame := data[headerSize : headerSize+frameSize]
+		data = data[headerSize+frameSize:]
+
+		if version == 4 {
+			// Compressed and encrypted frames aren't worth supporting
+			if frameFlags&0x000C != 0 {
+				continue
+			}
+			if frameFlags&0x0002 != 0 {
+				frame = unsynchronise(frame)
+			}
+			if frameFlags&0x0001 != 0 && len(frame) >= 4 {
+				frame = frame[4:]
+			}
+		} else if version == 3 && frameFlags&0x00C0 != 0 {
+			continue
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.title = id3Text(frame)
+		case "TPE1", "TP1":
+			tags.artist = id3Text(frame)
+		case "TALB", "TAL":
+			tags.album = id3Text(frame)
+		case "TLEN", "TLE":
+			if ms, err := strconv.Atoi(id3Text(frame)); err == nil && ms > 0 {
+				tags.duration = time.Duration(ms) * time.Millisecond
+			}
+		case "APIC", "PIC":
+			// Prefer the front cover, but take whatever is there first otherwise
+			if picture, mime, pictureType, ok := id3Picture(frame, id == "PIC"); ok && (tags.artwork == nil || pictureType == 3) {
+				tags.artwork, tags.artworkMime = picture, mime
+			}
+		}
+	}
+
+	if tags.duration == 0 {
+		tags.duration = mp3Duration(f, int64(10+size))
+	}
+
+	return tags, nil
+}
+
+// syncsafe decodes a syncsafe integer, where the top bit of each byte is always 0.
+func syncsafe(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<7 | uint32(c&0x7F)
+	}
+
+	return v
+}
+
+// unsynchronise reverses ID3 unsynchronisation, where a 0x00 is inserted after every 0xFF.
+func unsynchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// id3Text decodes the first string in an ID3 text frame.
+func id3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+
+	s, _ := id3String(frame[1:], frame[0])
+
+	return strings.TrimSpace(s)
+}
+
+// id3String decodes a single null terminated string in the given ID3 text encoding, returning it along with whatever
+// followed it.
+func id3String(b []byte, encoding byte) (string, []byte) {
+	switch encoding {
+	case 1, 2:
+		// UTF-16 terminates with two null bytes, aligned to a character
+		end := len(b) - len(b)%2
+		for index := 0; index+1 < len(b); index += 2 {
+			if b[index] == 0 && b[index+1] == 0 {
+				end = index
+				break
+			}
+		}
+		rest := b[min(end+2, len(b)):]
+		text := b[:end]
+
+		bigEndian := encoding == 2
+		if len(text) >= 2 && text[0] == 0xFE && text[1] == 0xFF {
+			bigEndian, text = true, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xFF && text[1] == 0xFE {
+			bigEndian, text = false, text[2:]
+		}
+
+		units := make([]uint16, len(text)/2)
+		for index := range units {
+			if bigEndian {
+				units[index] = binary.BigEndian.Uint16(text[index*2:])
+			} else {
+				units[index] = binary.LittleEndian.Uint16(text[index*2:])
+			}
+		}
+
+		return string(utf16.Decode(units)), rest
+	default:
+		end := bytes.IndexByte(b, 0)
+		if end == -1 {
+			end = len(b)
+		}
+		rest := b[min(end+1, len(b)):]
+
+		if encoding == 3 {
+			return string(b[:end]), rest
+		}
+
+		// ISO-8859-1 maps directly onto the first 256 code points
+		runes := make([]rune, end)
+		for index, c := range b[:end] {
+			runes[index] = rune(c)
+		}
+
+		return string(runes), rest
+	}
+}
+
+// id3Picture decodes an APIC frame, or a PIC frame from version 2.
+func id3Picture(frame []byte, v2 bool) ([]byte, string, byte, bool) {
+	if len(frame) < 2 {
+		return nil, "", 0, false
+	}
+	encoding := frame[0]
+	rest := frame[1:]
+
+	var mime string
+	if v2 {
+		if len(rest) < 3 {
+			return nil, "", 0, false
+		}
+		mime = "image/" + strings.ToLower(string(rest[:3]))
+		if mime == "image/jpg" {
+			mime = "image/jpeg"
+		}
+		rest = rest[3:]
+	} else {
+		mime, rest = id3String(rest, 0)
+	}
+
+	if len(rest) < 1 {
+		return nil, "", 0, false
+	}
+	pictureType := rest[0]
+	_, picture := id3String(rest[1:], encoding)
+
+	if len(picture) == 0 {
+		return nil, "", 0, false
+	}
+
+	return picture, mime, pictureType, true
+}
+
+// mp3Duration works out the duration of the mp3 frames starting at offset. A Xing/Info header is used if there is one,
+// otherwise the file is assumed to be constant bitrate. 0 is returned if no frame could be found.
+func mp3Duration(f *os.File, offset int64) time.Duration {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+
+	buf := make([]byte, 64<<10)
+	n, _ := f.ReadAt(buf, offset)
+	buf = buf[:n]
+
+	for index := 0; index+4 <= len(buf); index++ {
+		if buf[index] != 0xFF || buf[index+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		header := binary.BigEndian.Uint32(buf[index:])
+		versionBits := (header >> 19) & 0x3
+		layerBits := (header >> 17) & 0x3
+		bitrateIndex := (header >> 12) & 0xF
+		sampleRateIndex := (header >> 10) & 0x3
+		mono := (header>>6)&0x3 == 0x3
+		if versionBits == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 0xF || sampleRateIndex == 0x3 {
+			continue
+		}
+
+		v1 := versionBits == 3
+		layer := 4 - int(layerBits)
+		sampleRate := []int{44100, 48000, 32000}[sampleRateIndex]
+		switch versionBits {
+		case 2:
+			sampleRate /= 2
+		case 0:
+			sampleRate /= 4
+		}
+
+		samplesPerFrame := 1152
+		if layer == 1 {
+			samplesPerFrame = 384
+		} else if layer == 3 && !v1 {
+			samplesPerFrame = 576
+		}
+
+		// A Xing or Info header in the first frame holds the frame count of the whole file
+		sideInfo := 32
+		if v1 && mono || !v1 && !mono {
+			sideInfo = 17
+		} else if !v1 && mono {
+			sideInfo = 9
+		}
+		if xing := index + 4 + sideInfo; xing+12 <= len(buf) {
+			if id := string(buf[xing : xing+4]); (id == "Xing" || id == "Info") && buf[xing+7]&0x1 != 0 {
+				frames := binary.BigEndian.Uint32(buf[xing+8:])
+				return time.Duration(float64(frames) * float64(samplesPerFrame) / float64(sampleRate) * float64(time.Second))
+			}
+		}
+
+		bitrate := mp3Bitrate(v1, layer, int(bitrateIndex))
+		audioSize := stat.Size() - offset - int64(index)
+
+		return time.Duration(float64(audioSize) * 8 / float64(bitrate*1000) * float64(time.Second))
+	}
+
+	return 0
+}
+
+// mp3Bitrate returns the bitrate in kbps for an mp3 frame header.
+func mp3Bitrate(v1 bool, layer int, index int) int {
+	switch {
+	case v1 && layer == 1:
+		return []int{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448}[index]
+	case v1 && layer == 2:
+		return []int{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384}[index]
+	case v1:
+		return []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}[index]
+	case layer == 1:
+		return []int{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256}[index]
+	default:
+		return []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}[index]
+	}
+}
+
+// readFLAC reads the metadata blocks at the start of a FLAC file.
+func readFLAC(f *os.File) (audioTags, error) {
+	if _, err := f.Seek(4, io.SeekStart); err != nil {
+		return audioTags{}, err
+	}
+
+	var tags audioTags
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return tags, err
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch blockType {
+		case 0, 4, 6:
+			if size > maxTagSize {
+				return tags, nil
+			}
+
+			block := make([]byte, size)
+			if _, err := io.ReadFull(f, block); err != nil {
+				return tags, err
+			}
+
+			switch blockType {
+			case 0:
+				if len(block) >= 18 {
+					sampleRate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+					samples := int64(block[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+					if sampleRate > 0 {
+						tags.duration = time.Duration(samples * int64(time.Second) / sampleRate)
+					}
+				}
+			case 4:
+				readVorbisComment(block, &tags)
+			case 6:
+				if picture, mime, pictureType, ok := flacPicture(block); ok && (tags.artwork == nil || pictureType == 3) {
+					tags.artwork, tags.artworkMime = picture, mime
+				}
+			}
+		default:
+			if _, err := f.Seek(size, io.SeekCurrent); err != nil {
+				return tags, err
+			}
+		}
+
+		if last {
+			return tags, nil
+		}
+	}
+}
+
+// flacPicture decodes a FLAC picture block, which is also how vorbis comments embed artwork.
+func flacPicture(block []byte) ([]byte, string, uint32, bool) {
+	next := func(n int) ([]byte, bool) {
+		if n < 0 || n > len(block) {
+			return nil, false
+		}
+		b := block[:n]
+		block = block[n:]
+		return b, true
+	}
+	nextUint32 := func() (uint32, bool) {
+		b, ok := next(4)
+		if !ok {
+			return 0, false
+		}
+		return binary.BigEndian.Uint32(b), true
+	}
+
+	pictureType, ok := nextUint32()
+	if !ok {
+		return nil, "", 0, false
+	}
+
+	mimeLength, ok := nextUint32()
+	if !ok {
+		return nil, "", 0, false
+	}
+	mime, ok := next(int(mimeLength))
+	if !ok {
+		return nil, "", 0, false
+	}
+
+	descriptionLength, ok := nextUint32()
+	if !ok {
+		return nil, "", 0, false
+	}
+	// Skip the description, then the width, height, colour depth and number of colours
+	if _, ok = next(int(descriptionLength) + 16); !ok {
+		return nil, "", 0, false
+	}
+
+	dataLength, ok := nextUint32()
+	if !ok {
+		return nil, "", 0, false
+	}
+	picture, ok := next(int(dataLength))
+	if !ok || len(picture) == 0 {
+		return nil, "", 0, false
+	}
+
+	return picture, string(mime), pictureType, true
+}
+
+// readVorbisComment reads a vorbis comment into tags. Only the first value of each field is kept.
+func readVorbisComment(b []byte, tags *audioTags) {
+	if len(b) < 4 {
+		return
+	}
+	vendorLength := int(binary.LittleEndian.Uint32(b))
+	if vendorLength > len(b)-8 {
+		return
+	}
+	b = b[4+vendorLength:]
+
+	count := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	for ; count > 0 && len(b) >= 4; count-- {
+		length := int(binary.LittleEndian.Uint32(b))
+		if length > len(b)-4 {
+			return
+		}
+		comment := string(b[4 : 4+length])
+		b = b[4+length:]
+
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			if tags.title == "" {
+				tags.title = strings.TrimSpace(value)
+			}
+		case "ARTIST":
+			if tags.artist == "" {
+				tags.artist = strings.TrimSpace(value)
+			}
+		case "ALBUM":
+			if tags.album == "" {
+				tags.album = strings.TrimSpace(value)
+			}
+		case "METADATA_BLOCK_PICTURE":
+			block, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+
+			if picture, mime, pictureType, ok := flacPicture(block); ok && (tags.artwork == nil || pictureType == 3) {
+				tags.artwork, tags.artworkMime = picture, mime
+			}
+		}
+	}
+}
+
+// readOgg reads the comment header of the first stream in an Ogg file, which is either vorbis or opus. The duration
+// comes from the granule position of the last page.
+func readOgg(f *os.File) (audioTags, error) {
+	var tags audioTags
+	var serial uint32
+	var serialFound bool
+	var packets [][]byte
+	var packet []byte
+
+	header := make([]byte, 27)
+	for len(packets) < 2 {
+		if _, err := io.ReadFull(f, header); err != nil || string(header[:4]) != "OggS" {
+			return tags, errNoTags
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(f, segments); err != nil {
+			return tags, err
+		}
+
+		size := 0
+		for _, segment := range segments {
+			size += int(segment)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(f, data); err != nil {
+			return tags, err
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		if !serialFound {
+			serial, serialFound = pageSerial, true
+		} else if pageSerial != serial {
+			continue
+		}
+
+		// Packets are split into 255 byte segments, the last of which is always shorter
+		for _, segment := range segments {
+			packet = append(packet, data[:segment]...)
+			data = data[segment:]
+
+			if segment < 255 {
+				packets = append(packets, packet)
+				packet = []byte{}
+			}
+
+			if len(packet) > maxTagSize {
+				return tags, nil
+			}
+		}
+	}
+
+	var rate int64
+	var preSkip int64
+	switch {
+	case bytes.HasPrefix(packets[0], []byte("\x01vorbis")) && len(packets[0]) >= 16:
+		rate = int64(binary.LittleEndian.Uint32(packets[0][12:16]))
+		if bytes.HasPrefix(packets[1], []byte("\x03vorbis")) {
+			readVorbisComment(packets[1][7:], &tags)
+		}
+	case bytes.HasPrefix(packets[0], []byte("OpusHead")) && len(packets[0]) >= 12:
+		// Opus always counts granules at 48kHz
+		rate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packets[0][10:12]))
+		if bytes.HasPrefix(packets[1], []byte("OpusTags")) {
+			readVorbisComment(packets[1][8:], &tags)
+		}
+	default:
+		return tags, errNoTags
+	}
+
+	if granule := lastOggGranule(f, serial); granule > preSkip && rate > 0 {
+		tags.duration = time.Duration((granule - preSkip) * int64(time.Second) / rate)
+	}
+
+	return tags, nil
+}
+
+// lastOggGranule finds the granule position of the last page of the given stream, which is how many samples it has.
+func lastOggGranule(f *os.File, serial uint32) int64 {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+
+	// Pages are at most ~64KiB, so the last one is always within this
+	size := min(stat.Size(), 64<<10+27+255)
+	buf := make([]byte, size)
+	if _, err = f.ReadAt(buf, stat.Size()-size); err != nil && err != io.EOF {
+		return 0
+	}
+
+	for index := bytes.LastIndex(buf, []byte("OggS")); index != -1; index = bytes.LastIndex(buf[:index], []byte("OggS")) {
+		if index+27 > len(buf) || binary.LittleEndian.Uint32(buf[index+14:]) != serial {
+			continue
+		}
+
+		if granule := int64(binary.LittleEndian.Uint64(buf[index+6:])); granule >= 0 {
+			return granule
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,329 @@
+package spotify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFixture writes data to a temporary file and returns its path.
+func writeFixture(t testing.TB, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fixture")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// syncsafeBytes encodes v as a 4 byte syncsafe integer.
+func syncsafeBytes(v int) []byte {
+	return []byte{byte(v >> 21 & 0x7F), byte(v >> 14 & 0x7F), byte(v >> 7 & 0x7F), byte(v & 0x7F)}
+}
+
+// id3Tag builds an ID3v2 tag of the given version around data, which should already hold the frames.
+func id3Tag(version byte, flags byte, data []byte) []byte {
+	tag := append([]byte{'I', 'D', '3', version, 0, flags}, syncsafeBytes(len(data))...)
+	return append(tag, data...)
+}
+
+// id3Frame builds a single frame for the given tag version.
+func id3Frame(version byte, id string, flags uint16, data []byte) []byte {
+	frame := []byte(id)
+	switch version {
+	case 2:
+		frame = append(frame, byte(len(data)>>16), byte(len(data)>>8), byte(len(data)))
+	case 3:
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(data)))
+		frame = binary.BigEndian.AppendUint16(frame, flags)
+	default:
+		frame = append(frame, syncsafeBytes(len(data))...)
+		frame = binary.BigEndian.AppendUint16(frame, flags)
+	}
+
+	return append(frame, data...)
+}
+
+// latin1 builds the body of an ISO-8859-1 text frame.
+func latin1(s string) []byte {
+	return append([]byte{0}, s...)
+}
+
+// synchronise applies ID3 unsynchronisation, inserting a 0x00 after every 0xFF.
+func synchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF}, []byte{0xFF, 0x00})
+}
+
+// mp3Frame builds the start of an MPEG-1 layer 3 frame at 128kbps and 44.1kHz, optionally with a Xing header counting
+// frames frames.
+func mp3Frame(frames int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x64})
+	if frames > 0 {
+		// The Xing header follows the 32 bytes of side information used by stereo MPEG-1
+		copy(frame[36:], "Xing")
+		binary.BigEndian.PutUint32(frame[40:], 0x1)
+		binary.BigEndian.PutUint32(frame[44:], uint32(frames))
+	}
+
+	return frame
+}
+
+func TestReadTagsID3v2(t *testing.T) {
+	// Worked out the same way as mp3Duration so rounding doesn't get in the way
+	xingSeconds, cbrSeconds := float64(1000)*1152/44100, float64(417*40)*8/128000
+
+	tests := []struct {
+		name string
+		data []byte
+		want audioTags
+	}{
+		{
+			name: "v2.2",
+			data: id3Tag(2, 0, bytes.Join([][]byte{
+				id3Frame(2, "TT2", 0, latin1("Title")),
+				id3Frame(2, "TP1", 0, latin1("Artist")),
+				id3Frame(2, "TAL", 0, latin1("Album")),
+				id3Frame(2, "TLE", 0, latin1("61000")),
+			}, nil)),
+			want: audioTags{title: "Title", artist: "Artist", album: "Album", duration: 61 * time.Second},
+		},
+		{
+			name: "v2.3",
+			data: id3Tag(3, 0, bytes.Join([][]byte{
+				id3Frame(3, "TIT2", 0, latin1("Title")),
+				id3Frame(3, "TPE1", 0, latin1("Artist")),
+				id3Frame(3, "TLEN", 0, latin1("1500")),
+			}, nil)),
+			want: audioTags{title: "Title", artist: "Artist", duration: 1500 * time.Millisecond},
+		},
+		{
+			name: "v2.4 utf-8",
+			data: id3Tag(4, 0, id3Frame(4, "TIT2", 0, append([]byte{3}, "Tītle\x00"...))),
+			want: audioTags{title: "Tītle"},
+		},
+		{
+			name: "utf-16 little endian bom",
+			data: id3Tag(3, 0, id3Frame(3, "TIT2", 0, []byte{1, 0xFF, 0xFE, 'H', 0, 'i', 0, 0, 0})),
+			want: audioTags{title: "Hi"},
+		},
+		{
+			name: "utf-16 big endian bom",
+			data: id3Tag(3, 0, id3Frame(3, "TIT2", 0, []byte{1, 0xFE, 0xFF, 0, 'H', 0, 'i'})),
+			want: audioTags{title: "Hi"},
+		},
+		{
+			name: "utf-16 big endian without bom",
+			data: id3Tag(4, 0, id3Frame(4, "TIT2", 0, []byte{2, 0, 'H', 0, 'i'})),
+			want: audioTags{title: "Hi"},
+		},
+		{
+			name: "v2.3 extended header",
+			data: id3Tag(3, 0x40, append(
+				[]byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0},
+				id3Frame(3, "TIT2", 0, latin1("Title"))...,
+			)),
+			want: audioTags{title: "Title"},
+		},
+		{
+			name: "v2.4 extended header",
+			data: id3Tag(4, 0x40, append(
+				append(syncsafeBytes(6), 1, 0),
+				id3Frame(4, "TIT2", 0, latin1("Title"))...,
+			)),
+			want: audioTags{title: "Title"},
+		},
+		{
+			name: "v2.3 unsynchronisation",
+			data: id3Tag(3, 0x80, synchronise(id3Frame(3, "TIT2", 0, latin1("\xFFa")))),
+			want: audioTags{title: "ÿa"},
+		},
+		{
+			name: "v2.4 frame unsynchronisation",
+			data: id3Tag(4, 0, id3Frame(4, "TIT2", 0x0002, synchronise(latin1("\xFFa")))),
+			want: audioTags{title: "ÿa"},
+		},
+		{
+			name: "v2.4 compressed frame skipped",
+			data: id3Tag(4, 0, bytes.Join([][]byte{
+				id3Frame(4, "TIT2", 0x0008, latin1("Compressed")),
+				id3Frame(4, "TPE1", 0, latin1("Artist")),
+			}, nil)),
+			want: audioTags{artist: "Artist"},
+		},
+		{
+			name: "truncated frame",
+			data: id3Tag(3, 0, append(
+				id3Frame(3, "TIT2", 0, latin1("Title")),
+				[]byte{'T', 'P', 'E', '1', 0, 0, 1, 0, 0, 0, 0, 'A'}...,
+			)),
+			want: audioTags{title: "Title"},
+		},
+		{
+			name: "padding",
+			data: id3Tag(3, 0, append(id3Frame(3, "TIT2", 0, latin1("Title")), make([]byte, 32)...)),
+			want: audioTags{title: "Title"},
+		},
+		{
+			name: "xing header",
+			data: append(id3Tag(3, 0, id3Frame(3, "TIT2", 0, latin1("Title"))), mp3Frame(1000)...),
+			want: audioTags{title: "Title", duration: time.Duration(xingSeconds * float64(time.Second))},
+		},
+		{
+			name: "constant bitrate",
+			data: append(id3Tag(3, 0, nil), bytes.Repeat(mp3Frame(0), 40)...),
+			want: audioTags{duration: time.Duration(cbrSeconds * float64(time.Second))},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readTags(writeFixture(t, test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got.title != test.want.title || got.artist != test.want.artist || got.album != test.want.album {
+				t.Errorf("got %q/%q/%q, want %q/%q/%q",
+					got.title, got.artist, got.album, test.want.title, test.want.artist, test.want.album)
+			}
+			if got.duration != test.want.duration {
+				t.Errorf("got duration %s, want %s", got.duration, test.want.duration)
+			}
+		})
+	}
+}
+
+func TestReadTagsArtwork(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		want     []byte
+		wantMime string
+	}{
+		{
+			name:     "v2.2 PIC",
+			data:     id3Tag(2, 0, id3Frame(2, "PIC", 0, append([]byte{0, 'J', 'P', 'G', 3, 0}, jpeg...))),
+			want:     jpeg,
+			wantMime: "image/jpeg",
+		},
+		{
+			name:     "v2.3 APIC",
+			data:     id3Tag(3, 0, id3Frame(3, "APIC", 0, append([]byte("\x00image/png\x00\x03desc\x00"), png...))),
+			want:     png,
+			wantMime: "image/png",
+		},
+		{
+			name: "front cover preferred",
+			data: id3Tag(3, 0, bytes.Join([][]byte{
+				id3Frame(3, "APIC", 0, append([]byte("\x00image/jpeg\x00\x00\x00"), jpeg...)),
+				id3Frame(3, "APIC", 0, append([]byte("\x00image/png\x00\x03\x00"), png...)),
+			}, nil)),
+			want:     png,
+			wantMime: "image/png",
+		},
+		{
+			name: "empty picture ignored",
+			data: id3Tag(3, 0, id3Frame(3, "APIC", 0, []byte("\x00image/png\x00\x03\x00"))),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readTags(writeFixture(t, test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !bytes.Equal(got.artwork, test.want) || got.artworkMime != test.wantMime {
+				t.Errorf("got %x (%q), want %x (%q)", got.artwork, got.artworkMime, test.want, test.wantMime)
+			}
+		})
+	}
+}
+
+func TestReadTagsFLAC(t *testing.T) {
+	// 44.1kHz, 441000 samples
+	streamInfo := make([]byte, 34)
+	streamInfo[10], streamInfo[11], streamInfo[12] = 0x0A, 0xC4, 0x40
+	binary.BigEndian.PutUint32(streamInfo[14:], 441000)
+
+	comment := binary.LittleEndian.AppendUint32(nil, 0)
+	comment = binary.LittleEndian.AppendUint32(comment, 2)
+	for _, field := range []string{"TITLE=Title", "artist=Artist"} {
+		comment = binary.LittleEndian.AppendUint32(comment, uint32(len(field)))
+		comment = append(comment, field...)
+	}
+
+	data := []byte("fLaC")
+	data = append(data, 0, 0, 0, byte(len(streamInfo)))
+	data = append(data, streamInfo...)
+	data = append(data, 0x84, 0, 0, byte(len(comment)))
+	data = append(data, comment...)
+
+	got, err := readTags(writeFixture(t, data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.title != "Title" || got.artist != "Artist" || got.duration != 10*time.Second {
+		t.Errorf("got %q/%q/%s, want Title/Artist/10s", got.title, got.artist, got.duration)
+	}
+}
+
+func TestReadTagsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"unknown format", []byte("RIFF\x00\x00\x00\x00WAVE")},
+		{"unsupported version", id3Tag(5, 0, id3Frame(4, "TIT2", 0, latin1("Title")))},
+		{"truncated tag", append([]byte{'I', 'D', '3', 3, 0, 0}, syncsafeBytes(100)...)},
+		{"truncated flac", []byte("fLaC\x80\x00\x00")},
+		{"truncated ogg", []byte("OggS\x00")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readTags(writeFixture(t, test.data))
+			if err == nil {
+				t.Errorf("expected an error, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestReadTagsOversizeTag(t *testing.T) {
+	data := append([]byte{'I', 'D', '3', 3, 0, 0}, syncsafeBytes(maxTagSize+1)...)
+
+	if _, err := readTags(writeFixture(t, data)); !errors.Is(err, errNoTags) {
+		t.Errorf("got %v, want %v", err, errNoTags)
+	}
+}
+
+func FuzzReadTags(f *testing.F) {
+	f.Add(id3Tag(2, 0, id3Frame(2, "TT2", 0, latin1("Title"))))
+	f.Add(id3Tag(3, 0x40, append([]byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0}, id3Frame(3, "TIT2", 0, latin1("Title"))...)))
+	f.Add(id3Tag(3, 0x80, synchronise(id3Frame(3, "TIT2", 0, latin1("\xFFa")))))
+	f.Add(id3Tag(4, 0, id3Frame(4, "TIT2", 0x0003, []byte{0, 0, 0, 4, 1, 0xFF, 0xFE, 'H'})))
+	f.Add(id3Tag(3, 0, id3Frame(3, "APIC", 0, []byte("\x01image/png\x00\x03\xFF\xFEd\x00\x00\x00\x89PNG"))))
+	f.Add(append(id3Tag(3, 0, nil), mp3Frame(1000)...))
+	f.Add([]byte("fLaC\x80\x00\x00\x04\x00\x00\x00\x00"))
+	f.Add([]byte("fLaC\x86\x00\x00\x10\x00\x00\x00\x03\xFF\xFF\xFF\xFF\x00\x00\x00\x00\x00\x00"))
+	f.Add([]byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x08OpusHead"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Anything goes as long as it doesn't panic or hang
+		_, _ = readTags(writeFixture(t, data))
+	})
+}
@@ -0,0 +1,114 @@
+package spotify
+
+import (
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/olympus-go/apollo"
+	"golang.org/x/exp/slices"
+)
+
+// skipVote tracks the votes to skip a single playable.
+type skipVote struct {
+	mu       sync.Mutex
+	playable apollo.Playable
+	voters   []string
+
+	// channelId and messageId are the message holding the live tally. It's edited directly rather than through the
+	// interaction that posted it, since interaction tokens expire long before some tracks do.
+	channelId string
+	messageId string
+}
+
+// posted records the message holding the live tally.
+func (v *skipVote) posted(message *discordgo.Message) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.channelId = message.ChannelID
+	v.messageId = message.ID
+}
+
+// update replaces the live tally with content and components. Nothing happens if the tally hasn't been posted yet.
+func (v *skipVote) update(discordSession *discordgo.Session, content string, components []discordgo.MessageComponent) error {
+	v.mu.Lock()
+	channelId, messageId := v.channelId, v.messageId
+	v.mu.Unlock()
+
+	if messageId == "" {
+		return nil
+	}
+
+	if components == nil {
+		components = []discordgo.MessageComponent{}
+	}
+
+	edit := discordgo.NewMessageEdit(channelId, messageId).SetContent(content)
+	edit.Components = &components
+	_, err := discordSession.ChannelMessageEditComplex(edit)
+
+	return err
+}
+
+// cast registers userId's vote. ok is false if they had already voted.
+func (v *skipVote) cast(userId string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if slices.Contains(v.voters, userId) {
+		return false
+	}
+
+	v.voters = append(v.voters, userId)
+
+	return true
+}
+
+// count returns the number of votes cast by users in listeners. Votes from anyone who has since left don't count.
+func (v *skipVote) count(listeners []string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	count := 0
+	for _, voter := range v.voters {
+		if slices.Contains(listeners, voter) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// listeners returns the ids of everyone other than the bot in the session's voice channel.
+func (s *session) listeners(discordSession *discordgo.Session) ([]string, error) {
+	if s.voiceConnection == nil {
+		return nil, ErrNotInVoice
+	}
+
+	guild, err := discordSession.State.Guild(s.guildId)
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []string
+	for _, voiceState := range guild.VoiceStates {
+		if voiceState.ChannelID == s.voiceConnection.ChannelID && voiceState.UserID != discordSession.State.User.ID {
+			listeners = append(listeners, voiceState.UserID)
+		}
+	}
+
+	return listeners, nil
+}
+
+// votesNeeded returns the number of votes needed to skip with the given number of listeners. fraction falls back to a
+// simple majority if it isn't a number in (0, 1].
+func votesNeeded(listeners int, fraction string) int {
+	f, err := strconv.ParseFloat(fraction, 64)
+	if err != nil || f <= 0 || f > 1 {
+		f = 0.5
+	}
+
+	return max(1, int(math.Ceil(float64(listeners)*f)))
+}
@@ -0,0 +1,187 @@
+package spotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eolso/threadsafe"
+)
+
+const (
+	webApiUrl   = "https://api.spotify.com/v1"
+	webTokenUrl = "https://accounts.spotify.com/api/token"
+	// webCacheSize is the most tracks kept around before the cache is thrown out and started over.
+	webCacheSize = 2048
+)
+
+var errNoWebCredentials = errors.New("no spotify client credentials configured")
+
+// webApi is a minimal client for the parts of the spotify web API that spotify.Session doesn't cover, like albums and
+// the artist ids of a track. It authenticates with the client credentials flow, so it doesn't need anyone to be logged in.
+type webApi struct {
+	// config is read on every token request, since the client credentials are filled in by the host.
+	config *Config
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+
+	tracks *threadsafe.Map[string, webTrack]
+}
+
+// webTrack is the subset of a web API track object that's needed.
+type webTrack struct {
+	Artists []struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"artists"`
+	Album struct {
+		Id string `json:"id"`
+	} `json:"album"`
+}
+
+// webAlbum is the subset of a web API album object that's needed.
+type webAlbum struct {
+	Name   string `json:"name"`
+	Images []struct {
+		Url string `json:"url"`
+	} `json:"images"`
+	Tracks webTrackPage `json:"tracks"`
+}
+
+// webTrackPage is a single page of an album's tracks.
+type webTrackPage struct {
+	Items []struct {
+		Id string `json:"id"`
+	} `json:"items"`
+	Next string `json:"next"`
+}
+
+func newWebApi(config *Config) *webApi {
+	return &webApi{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		tracks: threadsafe.NewMap[string, webTrack](),
+	}
+}
+
+// track looks up the track with the given id. Results are cached, since bans are checked against the same tracks over
+// and over.
+func (w *webApi) track(id string) (webTrack, error) {
+	if t, ok := w.tracks.Get(id); ok {
+		return t, nil
+	}
+
+	var t webTrack
+	if err := w.get(webApiUrl+"/tracks/"+url.PathEscape(id), &t); err != nil {
+		return webTrack{}, err
+	}
+
+	if w.tracks.Len() >= webCacheSize {
+		w.tracks.Empty()
+	}
+	w.tracks.Set(id, t)
+
+	return t, nil
+}
+
+// album looks up the album with the given id, following the pages of its tracks until all of them are collected.
+func (w *webApi) album(id string) (webAlbum, error) {
+	var album webAlbum
+	if err := w.get(webApiUrl+"/albums/"+url.PathEscape(id), &album); err != nil {
+		return webAlbum{}, err
+	}
+
+	next := album.Tracks.Next
+	for next != "" {
+		var page webTrackPage
+		if err := w.get(next, &page); err != nil {
+			return webAlbum{}, err
+		}
+
+		album.Tracks.Items = append(album.Tracks.Items, page.Items...)
+		next = page.Next
+	}
+
+	return album, nil
+}
+
+// get requests u and decodes the JSON response into v.
+func (w *webApi) get(u string, v any) error {
+	token, err := w.accessToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("spotify web api returned %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// accessToken returns a client credentials token, requesting a new one if the last has expired.
+func (w *webApi) accessToken() (string, error) {
+	clientId, clientSecret := w.config.SpotifyClientId, w.config.SpotifyClientSecret
+	if clientId == "" || clientSecret == "" {
+		return "", errNoWebCredentials
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.token != "" && time.Now().Before(w.expires) {
+		return w.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequest(http.MethodPost, webTokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientId, clientSecret)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("spotify token request returned %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	// Renew a little early so a token never expires mid-request
+	w.token = token.AccessToken
+	w.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+
+	return w.token, nil
+}